@@ -0,0 +1,140 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"ripper/pkg/logs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBodySize 请求体和响应最多记录的字节数, 超出部分截断
+const MaxBodySize = 4 << 20
+
+// redacted 脱敏后的请求头取值
+const redacted = "***"
+
+// Record 一次请求的抓包记录, 每条记录占归档文件的一行
+type Record struct {
+	ID       string      `json:"id"` // 即 x-github-request-id
+	Time     time.Time   `json:"time"`
+	User     string      `json:"user"`
+	Route    string      `json:"route"`
+	Method   string      `json:"method"`
+	Path     string      `json:"path"` // 包含查询参数
+	Header   http.Header `json:"header"`
+	Body     string      `json:"body"`
+	Provider string      `json:"provider,omitempty"` // 实际请求的上游提供方
+	Model    string      `json:"model,omitempty"`    // 实际请求的上游模型
+
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header"`
+	Response       string      `json:"response"` // 返回给插件的原始响应, 流式响应为完整的 SSE 文本
+	Truncated      bool        `json:"truncated,omitempty"`
+	DurationMs     int64       `json:"duration_ms"`
+}
+
+// sensitiveHeader 需要脱敏的请求头
+var sensitiveHeader = regexp.MustCompile(`(?i)^(authorization|proxy-authorization|cookie|set-cookie|api-key|x-api-key)$|token|secret`)
+
+// RedactHeader 复制请求头并脱敏其中的令牌
+func RedactHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		list := make([]string, len(values))
+		for i, v := range values {
+			if sensitiveHeader.MatchString(name) {
+				list[i] = redacted
+			} else {
+				list[i] = logs.Scrub(v)
+			}
+		}
+		out[name] = list
+	}
+	return out
+}
+
+// IsRedacted 判断请求头是否已被脱敏, 重放时不发送
+func IsRedacted(name string) bool {
+	return sensitiveHeader.MatchString(name)
+}
+
+var fileMu sync.Mutex
+
+// Save 将记录追加到 <dir>/<用户>/<路由>-<日期>.jsonl
+func Save(dir string, r *Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, safeName(r.User), fmt.Sprintf("%s-%s.jsonl", safeName(r.Route), r.Time.Format("2006-01-02")))
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create capture directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %v", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Find 从归档文件中查找记录, id 为空时返回最后一条
+func Find(path string, id string) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var found *Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*MaxBodySize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(line, r); err != nil {
+			return nil, fmt.Errorf("failed to parse capture file %s: %v", path, err)
+		}
+		if id == "" || r.ID == id {
+			found = r
+			if id != "" {
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("capture record %q not found in %s", id, path)
+	}
+	return found, nil
+}
+
+// safeName 将用户标识等转换为可用作文件名的字符串
+func safeName(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,136 @@
+package fim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+// builtinTemplates 内置模板, 保持原有模型的兼容处理
+var builtinTemplates = []*Template{
+	{
+		// https://ollama.com/library/stable-code || https://ollama.com/library/codegemma
+		Name:   "stable-code",
+		Match:  []string{"stable-code", "codegemma"},
+		Mode:   ModeChat,
+		Prefix: "<fim_prefix>",
+		Suffix: "<fim_suffix>",
+		Middle: "<fim_middle>",
+	},
+	{
+		// https://ollama.com/library/codellama
+		Name:     "codellama",
+		Match:    []string{"codellama"},
+		Mode:     ModeChat,
+		Template: "<PRE> {{prefix}} <SUF> {{suffix}} <MID>",
+	},
+	{
+		// https://help.aliyun.com/zh/model-studio/user-guide/qwen-coder
+		Name:   "qwen-coder-turbo",
+		Match:  []string{"qwen-coder-turbo"},
+		Mode:   ModeChat,
+		System: "You are an expert in {{language}} programming, highly skilled at understanding and continuing to write code.",
+		Template: "Combined with subsequent code snippets, help me complete the code:\n\n" +
+			"Code subsequent content:\n```{{language}}\n{{suffix}}```\n\n" +
+			"Remember:\n" +
+			"- Do not generate content outside of the code.\n" +
+			"- Do not directly fill in all the code content, the maximum number of lines of code should not exceed 5 lines.\n" +
+			"- Answer must refer to the code suffix content, do not exceed the boundary, otherwise repeated code will occur.\n" +
+			"- If you don't know how to answer, just reply with an empty string.",
+		Prefill: true,
+	},
+}
+
+// templates 当前生效的模板列表, 热加载时整体替换
+var templates atomic.Pointer[[]*Template]
+
+func init() {
+	Set(builtinTemplates)
+}
+
+// Init 加载模板配置文件, 配置文件中的模板优先于内置模板匹配, 同名时覆盖内置模板
+func Init(path string) error {
+	t, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Set(t)
+	return nil
+}
+
+// Set 替换当前生效的模板列表
+func Set(t []*Template) {
+	templates.Store(&t)
+}
+
+// Load 从配置文件加载模板列表, 文件不存在时仅使用内置模板
+func Load(path string) ([]*Template, error) {
+	if path == "" {
+		return builtinTemplates, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return builtinTemplates, nil
+		}
+		return nil, fmt.Errorf("failed to read fim templates file %s: %v", path, err)
+	}
+
+	var custom []*Template
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse fim templates file %s: %v", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i, t := range custom {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("invalid fim template #%d in %s: %v", i, path, err)
+		}
+		names[t.Name] = true
+	}
+
+	result := custom
+	for _, t := range builtinTemplates {
+		if !names[t.Name] {
+			result = append(result, t)
+		}
+	}
+
+	slog.Info("loaded fim templates file", "templates", len(custom), "path", path)
+	return result, nil
+}
+
+// validate 校验模板配置
+func (t *Template) validate() error {
+	if t == nil {
+		return fmt.Errorf("template is empty")
+	}
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(t.Match) == 0 {
+		return fmt.Errorf("template %s has no match patterns", t.Name)
+	}
+	switch t.Mode {
+	case "":
+		t.Mode = ModeCompletion
+	case ModeChat, ModeCompletion, ModeNative:
+	default:
+		return fmt.Errorf("template %s has unsupported mode %s", t.Name, t.Mode)
+	}
+	return nil
+}
+
+// Find 查找模型适用的模板, 按配置顺序返回第一个匹配的模板
+func Find(model string) (*Template, bool) {
+	for _, t := range *templates.Load() {
+		if t.Matches(model) {
+			return t, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,125 @@
+package fim
+
+import (
+	"path"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 请求构建方式
+const (
+	// ModeChat 将填充内容放入对话消息, 请求对话接口
+	ModeChat = "chat"
+	// ModeCompletion 将填充内容渲染为 prompt, 请求原始补全接口
+	ModeCompletion = "completion"
+	// ModeNative 保留 prompt 和 suffix, 由上游原生处理 FIM
+	ModeNative = "native"
+)
+
+// Template FIM 提示词模板
+type Template struct {
+	Name  string   `json:"name"`
+	Match []string `json:"match"` // 模型名称匹配规则, 包含 * 时按通配符匹配, 否则按包含匹配
+	Mode  string   `json:"mode"`  // 请求构建方式: chat completion native, 默认 completion
+
+	Prefix string `json:"prefix"` // 前缀标记, 如 <fim_prefix>
+	Suffix string `json:"suffix"` // 后缀标记, 如 <fim_suffix>
+	Middle string `json:"middle"` // 中间标记, 如 <fim_middle>
+
+	// Template 自定义填充内容, 支持 {{prefix}} {{suffix}} {{language}} 占位符
+	// 为空时按 前缀标记+代码前缀+后缀标记+代码后缀+中间标记 拼接
+	Template string `json:"template"`
+	// System chat 模式下的系统提示词, 支持占位符
+	System string `json:"system"`
+	// Prefill chat 模式下是否将代码前缀作为 assistant 预填充消息
+	Prefill bool `json:"prefill"`
+
+	Stop   []string               `json:"stop"`   // 追加的停止词
+	Params map[string]interface{} `json:"params"` // 额外的请求参数, 键支持 options.num_ctx 形式的路径
+}
+
+// Matches 判断模板是否适用于指定模型
+func (t *Template) Matches(model string) bool {
+	for _, pattern := range t.Match {
+		if strings.Contains(pattern, "*") {
+			if ok, _ := path.Match(pattern, model); ok {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(model, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 按模板重写代码补全请求体, language 为当前文件的编程语言
+func (t *Template) Apply(body []byte, language string) []byte {
+	prompt := gjson.GetBytes(body, "prompt").String()
+	suffix := gjson.GetBytes(body, "suffix").String()
+	replacer := strings.NewReplacer(
+		"{{prefix}}", prompt,
+		"{{suffix}}", suffix,
+		"{{language}}", language,
+	)
+
+	content := t.Template
+	if content == "" {
+		content = t.Prefix + "{{prefix}}" + t.Suffix + "{{suffix}}" + t.Middle
+	}
+	content = replacer.Replace(content)
+
+	switch t.Mode {
+	case ModeNative:
+	case ModeChat:
+		messages := make([]map[string]interface{}, 0, 3)
+		if t.System != "" {
+			messages = append(messages, map[string]interface{}{"role": "system", "content": replacer.Replace(t.System)})
+		}
+		messages = append(messages, map[string]interface{}{"role": "user", "content": content})
+		if t.Prefill {
+			messages = append(messages, map[string]interface{}{"role": "assistant", "content": prompt, "partial": true})
+		}
+		body, _ = sjson.SetBytes(body, "messages", messages)
+		body, _ = sjson.DeleteBytes(body, "prompt")
+		body, _ = sjson.DeleteBytes(body, "suffix")
+	default:
+		body, _ = sjson.SetBytes(body, "prompt", content)
+		body, _ = sjson.DeleteBytes(body, "suffix")
+	}
+
+	if len(t.Stop) > 0 {
+		body, _ = sjson.SetBytes(body, "stop", mergeStop(gjson.GetBytes(body, "stop"), t.Stop))
+	}
+	for key, value := range t.Params {
+		body, _ = sjson.SetBytes(body, key, value)
+	}
+	return body
+}
+
+// mergeStop 合并请求中的停止词与模板停止词, 去除重复项
+func mergeStop(stop gjson.Result, extra []string) []string {
+	merged := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			merged = append(merged, s)
+		}
+	}
+
+	if stop.IsArray() {
+		for _, s := range stop.Array() {
+			add(s.String())
+		}
+	} else {
+		add(stop.String())
+	}
+	for _, s := range extra {
+		add(s)
+	}
+	return merged
+}
@@ -0,0 +1,91 @@
+package fim
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestApply(t *testing.T) {
+	body := `{"prompt":"func main() {","suffix":"}","stop":["\n\n"],"max_tokens":50}`
+
+	tests := []struct {
+		name     string
+		template *Template
+		want     map[string]string // 请求体的 gjson 路径 -> 期望值
+	}{
+		{
+			name:     "completion tokens",
+			template: &Template{Mode: ModeCompletion, Prefix: "<PRE>", Suffix: "<SUF>", Middle: "<MID>"},
+			want: map[string]string{
+				"prompt": "<PRE>func main() {<SUF>}<MID>",
+				"suffix": "",
+			},
+		},
+		{
+			name:     "native",
+			template: &Template{Mode: ModeNative, Stop: []string{"\n\n", "<EOT>"}, Params: map[string]interface{}{"options.num_ctx": 4096}},
+			want: map[string]string{
+				"prompt":          "func main() {",
+				"suffix":          "}",
+				"stop.#":          "2",
+				"stop.1":          "<EOT>",
+				"options.num_ctx": "4096",
+			},
+		},
+		{
+			name: "chat",
+			template: &Template{
+				Mode:     ModeChat,
+				System:   "You write {{language}}.",
+				Template: "suffix: {{suffix}}",
+				Prefill:  true,
+			},
+			want: map[string]string{
+				"messages.#":         "3",
+				"messages.0.content": "You write go.",
+				"messages.1.content": "suffix: }",
+				"messages.2.role":    "assistant",
+				"messages.2.content": "func main() {",
+				"messages.2.partial": "true",
+				"prompt":             "",
+				"suffix":             "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.template.Apply([]byte(body), "go")
+			for key, want := range tt.want {
+				if value := gjson.GetBytes(got, key).String(); value != want {
+					t.Errorf("%s = %q, want %q\nbody: %s", key, value, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		model string
+		name  string
+	}{
+		{"codellama:7b-code", "codellama"},
+		{"stable-code:3b", "stable-code"},
+		{"codegemma:2b", "stable-code"},
+		{"qwen-coder-turbo-latest", "qwen-coder-turbo"},
+		{"gpt-4o", ""},
+	}
+	for _, tt := range tests {
+		template, ok := Find(tt.model)
+		if tt.name == "" {
+			if ok {
+				t.Errorf("Find(%s) = %s, want no template", tt.model, template.Name)
+			}
+			continue
+		}
+		if !ok || template.Name != tt.name {
+			t.Errorf("Find(%s) = %v, %v, want %s", tt.model, template, ok, tt.name)
+		}
+	}
+}
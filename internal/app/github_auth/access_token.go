@@ -5,7 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/gofrs/uuid"
-	"os"
+	"ripper/internal/config"
 	"sort"
 	"strings"
 )
@@ -55,6 +55,6 @@ func JsonMap2SignToken(data map[string]interface{}) string {
 }
 
 func Token2Sign(token string) string {
-	sign := sha256Sign(token + fmt.Sprintf(";salt=%s", os.Getenv("TOKEN_SALT")))
+	sign := sha256Sign(token + fmt.Sprintf(";salt=%s", config.Current().Auth.TokenSalt))
 	return sign
 }
@@ -1,10 +1,9 @@
 package github_auth
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/gofrs/uuid"
-	"github.com/gomodule/redigo/redis"
 	"ripper/internal/cache"
 	"strings"
 )
@@ -15,6 +14,8 @@ type ClientAuthInfo struct {
 	DeviceCode      string `json:"device_code"`
 	UserCode        string `json:"user_code"`
 	CardCode        string `json:"card_code"`
+	UserID          string `json:"user_id,omitempty"`
+	TokenID         string `json:"token_id,omitempty"`
 }
 
 type ClientOAuthInfo struct {
@@ -28,85 +29,70 @@ type ClientOAuthInfo struct {
 // clientId 客户端ID
 // exp 过期时间
 // return 用户代码, 设备代码, 错误
-func BindClientToCode(clientId string, exp int) (string, string, error) {
+func BindClientToCode(ctx context.Context, clientId string, exp int) (string, string, error) {
 	genCode := func() string {
 		newUUID, _ := uuid.NewV4()
 		uuidStr := strings.Replace(newUUID.String(), "-", "", -1)
 		return uuidStr[:6]
 	}
-	formattedUUID := genCode()
-	rep := 0
-	redisKey := fmt.Sprintf("copilot.proxy.%s", formattedUUID)
-	repeat, _ := cache.Exist(redisKey)
-	for repeat {
-		if rep > 5 {
-			return "", "", fmt.Errorf("gen code error")
-		}
-		formattedUUID = genCode()
-		redisKey = fmt.Sprintf("copilot.proxy.%s", formattedUUID)
-		repeat, _ = cache.Exist(redisKey)
-		rep++
-	}
 	devId := GenDevicesCode(40)
-	authInfo := ClientAuthInfo{
-		ClientId:   clientId,
-		DeviceCode: devId,
-		UserCode:   formattedUUID,
-	}
-	authInfoData, _ := json.Marshal(authInfo)
-	err := cache.Set(redisKey, authInfoData, exp)
-	if err != nil {
-		return "", "", err
+	for rep := 0; rep <= 5; rep++ {
+		formattedUUID := genCode()
+		authInfo := ClientAuthInfo{
+			ClientId:   clientId,
+			DeviceCode: devId,
+			UserCode:   formattedUUID,
+		}
+		// 用户代码可能重复, 仅在未被占用时写入, 避免并发请求覆盖彼此的绑定
+		ok, err := cache.SetNX(ctx, userCodeKey(formattedUUID), authInfo, exp)
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			continue
+		}
+		err = cache.Set(ctx, deviceCodeKey(devId), formattedUUID, exp)
+		return formattedUUID, devId, err
 	}
-	redisKey = fmt.Sprintf("copilot.proxy.map.%s", devId)
-	err = cache.Set(redisKey, formattedUUID, exp)
-	return formattedUUID, devId, err
+	return "", "", fmt.Errorf("gen code error")
 }
 
 // GetClientAuthInfoByDeviceCode 通过设备代码获取客户端授权信息
-func GetClientAuthInfoByDeviceCode(deviceCode string) (*ClientAuthInfo, error) {
-	redisKey := fmt.Sprintf("copilot.proxy.map.%s", deviceCode)
-	userCode, err := cache.Get(redisKey)
+func GetClientAuthInfoByDeviceCode(ctx context.Context, deviceCode string) (*ClientAuthInfo, error) {
+	userCode, ok, err := cache.Get[string](ctx, deviceCodeKey(deviceCode))
 	if err != nil {
 		return nil, err
 	}
-	redisKey = fmt.Sprintf("copilot.proxy.%s", userCode)
-	authInfoData, err := redis.Bytes(cache.Get(redisKey))
-	if err != nil {
-		return nil, err
+	if !ok {
+		return nil, fmt.Errorf("device code not found")
 	}
-	authInfo := &ClientAuthInfo{}
-	err = json.Unmarshal(authInfoData, &authInfo)
-	return authInfo, err
-}
-
-func GetOAuthCodeInfoByClientIdAndCode(clientId string, code string) (*ClientOAuthInfo, error) {
-	cacheKey := "oauth2_authorize_" + clientId
-	oauthCodeData, err := redis.Bytes(cache.Get(cacheKey))
+	authInfo, err := GetClientAuthInfo(ctx, userCode)
 	if err != nil {
 		return nil, err
 	}
+	return &authInfo, nil
+}
 
-	var oauthCode ClientOAuthInfo
-	err = json.Unmarshal(oauthCodeData, &oauthCode)
+func GetOAuthCodeInfoByClientIdAndCode(ctx context.Context, clientId string, code string) (*ClientOAuthInfo, error) {
+	oauthCode, ok, err := cache.Get[ClientOAuthInfo](ctx, OAuthCodeKey(clientId))
 	if err != nil {
 		return nil, err
 	}
-	if oauthCode.Code != code {
+	if !ok || oauthCode.Code != code {
 		return nil, fmt.Errorf("invalid oauth code")
 	}
 	return &oauthCode, nil
 }
 
-func GetClientAuthInfo(code string) (ClientAuthInfo, error) {
-	redisKey := fmt.Sprintf("copilot.proxy.%s", code)
-	authInfoData, err := redis.Bytes(cache.Get(redisKey))
+func GetClientAuthInfo(ctx context.Context, code string) (ClientAuthInfo, error) {
+	authInfo, ok, err := cache.Get[ClientAuthInfo](ctx, userCodeKey(code))
 	if err != nil {
 		return ClientAuthInfo{}, err
 	}
-	var authInfo ClientAuthInfo
-	err = json.Unmarshal(authInfoData, &authInfo)
-	return authInfo, err
+	if !ok {
+		return ClientAuthInfo{}, fmt.Errorf("user code not found")
+	}
+	return authInfo, nil
 }
 
 // GenDevicesCode 生成设备代码
@@ -120,19 +106,8 @@ func GenDevicesCode(codeLen int) string {
 }
 
 // UpdateClientAuthStatusByDeviceCode 更新客户端授权码通过设备代码
-func UpdateClientAuthStatusByDeviceCode(deviceCode string, cardCode string, displayUserName string) error {
-	redisKey := fmt.Sprintf("copilot.proxy.map.%s", deviceCode)
-	uCode, err := cache.Get(redisKey)
-	if err != nil {
-		return err
-	}
-	redisKey = fmt.Sprintf("copilot.proxy.%s", uCode)
-	authInfoData, err := redis.Bytes(cache.Get(redisKey))
-	if err != nil {
-		return err
-	}
-	authInfo := &ClientAuthInfo{}
-	err = json.Unmarshal(authInfoData, &authInfo)
+func UpdateClientAuthStatusByDeviceCode(ctx context.Context, deviceCode string, cardCode string, displayUserName string) error {
+	authInfo, err := GetClientAuthInfoByDeviceCode(ctx, deviceCode)
 	if err != nil {
 		return err
 	}
@@ -140,23 +115,45 @@ func UpdateClientAuthStatusByDeviceCode(deviceCode string, cardCode string, disp
 	if displayUserName != "" {
 		authInfo.DisplayUserName = displayUserName
 	}
-	authInfoData, _ = json.Marshal(authInfo)
-	err = cache.Set(redisKey, authInfoData, -1)
-	return err
+	return cache.Set(ctx, userCodeKey(authInfo.UserCode), authInfo, -1)
 }
 
-func RemoveClientAuthInfoByDeviceCode(deviceCode string) error {
-	redisKey := fmt.Sprintf("copilot.proxy.map.%s", deviceCode)
-	uCode, err := cache.Get(redisKey)
+// UpdateClientAuthUserByDeviceCode 用户模式下将设备代码绑定到访问令牌所属的用户
+// 不保存明文令牌, CardCode 记录令牌 ID 仅用于标记已授权
+func UpdateClientAuthUserByDeviceCode(ctx context.Context, deviceCode string, userID string, tokenID string, displayUserName string) error {
+	authInfo, err := GetClientAuthInfoByDeviceCode(ctx, deviceCode)
 	if err != nil {
 		return err
 	}
-	redisKey = fmt.Sprintf("copilot.proxy.%s", uCode)
-	err = cache.Del(redisKey)
-	if err != nil {
+	authInfo.CardCode = tokenID
+	authInfo.UserID = userID
+	authInfo.TokenID = tokenID
+	if displayUserName != "" {
+		authInfo.DisplayUserName = displayUserName
+	}
+	return cache.Set(ctx, userCodeKey(authInfo.UserCode), authInfo, -1)
+}
+
+// RemoveClientAuthInfoByDeviceCode 删除设备代码及其绑定的授权信息
+func RemoveClientAuthInfoByDeviceCode(ctx context.Context, deviceCode string) error {
+	userCode, ok, err := cache.GetDel[string](ctx, deviceCodeKey(deviceCode))
+	if err != nil || !ok {
 		return err
 	}
-	redisKey = fmt.Sprintf("copilot.proxy.map.%s", deviceCode)
-	err = cache.Del(redisKey)
-	return err
+	return cache.Del(ctx, userCodeKey(userCode))
+}
+
+// OAuthCodeKey OAuth 授权码的缓存键
+func OAuthCodeKey(clientId string) string {
+	return "oauth2_authorize_" + clientId
+}
+
+// userCodeKey 用户代码绑定的授权信息的缓存键
+func userCodeKey(userCode string) string {
+	return fmt.Sprintf("copilot.proxy.%s", userCode)
+}
+
+// deviceCodeKey 设备代码到用户代码映射的缓存键
+func deviceCodeKey(deviceCode string) string {
+	return fmt.Sprintf("copilot.proxy.map.%s", deviceCode)
 }
@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"ripper/internal/app/tracing"
+	"ripper/internal/config"
+	"sync"
+	"time"
+)
+
+// ErrIdleTimeout 上游流式响应超过 UPSTREAM_IDLE_TIMEOUT 没有新数据
+var ErrIdleTimeout = errors.New("upstream stream idle timeout")
+
+// Options 上游 http 客户端配置, 相同名称的配置变化时重新创建客户端
+type Options struct {
+	ConnectTimeout      time.Duration // 建立 TCP 连接及 TLS 握手的超时
+	TTFBTimeout         time.Duration // 发送请求后等待响应头的超时, 0 表示不限制
+	IdleTimeout         time.Duration // 响应流两次读取之间的最长间隔, 0 表示不限制
+	HTTP2               bool
+	CAFile              string // 额外信任的 CA 证书文件 (PEM)
+	InsecureSkipVerify  bool
+	MaxIdleConnsPerHost int
+	Untraced            bool // 不记录链路追踪, 用于导出 span 的客户端, 避免导出请求本身产生 span
+}
+
+// FromConfig 根据全局上游配置生成客户端配置
+func FromConfig(cfg config.UpstreamConfig) Options {
+	return Options{
+		ConnectTimeout:      time.Duration(cfg.ConnectTimeout) * time.Second,
+		TTFBTimeout:         time.Duration(cfg.TTFBTimeout) * time.Second,
+		IdleTimeout:         time.Duration(cfg.IdleTimeout) * time.Second,
+		HTTP2:               cfg.HTTP2,
+		CAFile:              cfg.CAFile,
+		InsecureSkipVerify:  cfg.InsecureSkipVerify,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+	}
+}
+
+type entry struct {
+	opts   Options
+	client *http.Client
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*entry)
+)
+
+// Get 获取指定名称的共享客户端, 首次调用或配置变化时重新创建, 同一上游的请求复用连接
+func Get(name string, opts Options) (*http.Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	old, ok := clients[name]
+	if ok && old.opts == opts {
+		return old.client, nil
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client for %s: %v", name, err)
+	}
+	clients[name] = &entry{opts: opts, client: client}
+	// 正在使用的连接不受影响, 只关闭旧客户端的空闲连接
+	if ok {
+		old.client.CloseIdleConnections()
+	}
+	return client, nil
+}
+
+// newClient 创建带连接池的客户端, 不设置整体超时, 流式响应由 IdleTimeout 控制
+func newClient(opts Options) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.CAFile != "" {
+		pool, err := loadCAFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   opts.ConnectTimeout,
+		ResponseHeaderTimeout: opts.TTFBTimeout,
+		ForceAttemptHTTP2:     opts.HTTP2,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if !opts.HTTP2 {
+		// 非 nil 的空 map 禁用 HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	var rt http.RoundTripper = transport
+	if opts.IdleTimeout > 0 {
+		rt = &idleTransport{base: transport, timeout: opts.IdleTimeout}
+	}
+	if !opts.Untraced {
+		rt = tracing.Transport(rt)
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// loadCAFile 在系统证书的基础上追加 CA 证书
+func loadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s: %v", path, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
+
+// idleTransport 响应流超过指定时间没有新数据时中断请求
+type idleTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	resp.Body = &idleBody{
+		ReadCloser: resp.Body,
+		ctx:        ctx,
+		cancel:     cancel,
+		timeout:    t.timeout,
+		timer:      time.AfterFunc(t.timeout, func() { cancel(ErrIdleTimeout) }),
+	}
+	return resp, nil
+}
+
+// idleBody 每次读取到数据后重新计时
+type idleBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && err != io.EOF && errors.Is(context.Cause(b.ctx), ErrIdleTimeout) {
+		err = ErrIdleTimeout
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
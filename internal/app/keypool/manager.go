@@ -0,0 +1,127 @@
+package keypool
+
+import (
+	"ripper/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	pools   = make(map[string]*Pool)
+	poolsMu sync.Mutex
+)
+
+// KeyState key 的健康状态, key 值已脱敏
+type KeyState struct {
+	Key            string     `json:"key"`
+	Weight         int        `json:"weight"`
+	Inflight       int        `json:"inflight"`
+	Uses           int64      `json:"uses"`
+	Failures       int        `json:"failures"`
+	Exhausted      bool       `json:"exhausted"`
+	ExhaustedUntil *time.Time `json:"exhausted_until,omitempty"`
+	LastStatus     int        `json:"last_status,omitempty"`
+	LastUsed       *time.Time `json:"last_used,omitempty"`
+}
+
+// PoolState key 池的状态
+type PoolState struct {
+	Name     string     `json:"name"`
+	Strategy string     `json:"strategy"`
+	Keys     []KeyState `json:"keys"`
+}
+
+// Get 获取指定名称的 key 池, 首次调用或 key 列表变化时重新创建
+// strategy 为空时使用全局配置的 KEY_POOL_STRATEGY
+func Get(name string, values []string, strategy string) *Pool {
+	if strategy == "" {
+		strategy = config.Current().Upstream.KeyPoolStrategy
+	}
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	old, ok := pools[name]
+	if ok && old.strategy == strategy && sameKeys(old, values) {
+		return old
+	}
+
+	p := newPool(name, values, strategy, old)
+	pools[name] = p
+	return p
+}
+
+// sameKeys 判断 key 列表是否与池中一致
+func sameKeys(p *Pool, values []string) bool {
+	parsed := make([]string, 0, len(values))
+	for _, raw := range values {
+		if value, _ := parseKey(raw); value != "" {
+			parsed = append(parsed, value)
+		}
+	}
+	if len(parsed) != len(p.keys) {
+		return false
+	}
+	for i, k := range p.keys {
+		if k.value != parsed[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Snapshot 获取所有 key 池的状态
+func Snapshot() []PoolState {
+	poolsMu.Lock()
+	all := make([]*Pool, 0, len(pools))
+	for _, p := range pools {
+		all = append(all, p)
+	}
+	poolsMu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+	now := time.Now()
+	states := make([]PoolState, 0, len(all))
+	for _, p := range all {
+		p.mu.Lock()
+		state := PoolState{Name: p.name, Strategy: p.strategy, Keys: make([]KeyState, 0, len(p.keys))}
+		if state.Strategy == "" {
+			state.Strategy = StrategyRoundRobin
+		}
+		for _, k := range p.keys {
+			state.Keys = append(state.Keys, KeyState{
+				Key:            mask(k.value),
+				Weight:         k.weight,
+				Inflight:       k.inflight,
+				Uses:           k.uses,
+				Failures:       k.failures,
+				Exhausted:      now.Before(k.exhaustedUntil),
+				ExhaustedUntil: timeOrNil(k.exhaustedUntil),
+				LastStatus:     k.lastStatus,
+				LastUsed:       timeOrNil(k.lastUsed),
+			})
+		}
+		p.mu.Unlock()
+		states = append(states, state)
+	}
+	return states
+}
+
+// timeOrNil 零值时间返回 nil, 避免输出无意义的时间
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// mask 脱敏 key, 仅保留首尾各4位
+func mask(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-8) + value[len(value)-4:]
+}
@@ -0,0 +1,213 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"ripper/internal/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 选择策略
+const (
+	StrategyRoundRobin = "round-robin"
+	StrategyWeighted   = "weighted"
+	StrategyLeastUsed  = "least-used"
+)
+
+const (
+	defaultBackoff = 60 * time.Second
+	maxBackoff     = time.Hour
+)
+
+var (
+	// ErrEmpty key 池中没有配置任何 key
+	ErrEmpty = errors.New("key pool is empty")
+	// ErrExhausted key 池中所有 key 都处于退避状态
+	ErrExhausted = errors.New("all keys in pool are exhausted")
+)
+
+// Key 单个 key 及其健康状态
+type Key struct {
+	value          string
+	weight         int
+	currentWeight  int
+	inflight       int
+	uses           int64
+	failures       int
+	exhaustedUntil time.Time
+	lastStatus     int
+	lastUsed       time.Time
+}
+
+// Value 获取 key 的原始值
+func (k *Key) Value() string {
+	return k.value
+}
+
+// Pool 一组可轮换使用的 key
+type Pool struct {
+	name     string
+	strategy string
+	keys     []*Key
+	cursor   int
+	mu       sync.Mutex
+}
+
+// newPool 创建 key 池, 保留旧池中仍然存在的 key 的状态
+func newPool(name string, values []string, strategy string, old *Pool) *Pool {
+	p := &Pool{name: name, strategy: strategy}
+
+	existing := make(map[string]Key)
+	if old != nil {
+		old.mu.Lock()
+		for _, k := range old.keys {
+			existing[k.value] = *k
+		}
+		old.mu.Unlock()
+	}
+
+	for _, raw := range values {
+		value, weight := parseKey(raw)
+		if value == "" {
+			continue
+		}
+		k, ok := existing[value]
+		if !ok {
+			k = Key{value: value}
+		}
+		k.weight = weight
+		k.inflight = 0
+		p.keys = append(p.keys, &k)
+	}
+	return p
+}
+
+// parseKey 解析 key 的权重, 格式: key#权重
+func parseKey(raw string) (string, int) {
+	raw = strings.TrimSpace(raw)
+	idx := strings.LastIndex(raw, "#")
+	if idx == -1 {
+		return raw, 1
+	}
+	weight, err := strconv.Atoi(raw[idx+1:])
+	if err != nil || weight <= 0 {
+		return raw, 1
+	}
+	return raw[:idx], weight
+}
+
+// Len 获取 key 的数量
+func (p *Pool) Len() int {
+	return len(p.keys)
+}
+
+// Acquire 按策略选择一个可用的 key, 使用完毕后必须调用 Report
+func (p *Pool) Acquire() (*Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) == 0 {
+		return nil, ErrEmpty
+	}
+
+	now := time.Now()
+	available := make([]*Key, 0, len(p.keys))
+	for _, k := range p.keys {
+		if now.After(k.exhaustedUntil) {
+			available = append(available, k)
+		}
+	}
+	if len(available) == 0 {
+		return nil, ErrExhausted
+	}
+
+	var selected *Key
+	switch p.strategy {
+	case StrategyWeighted:
+		selected = p.pickWeighted(available)
+	case StrategyLeastUsed:
+		selected = p.pickLeastUsed(available)
+	default:
+		selected = available[p.cursor%len(available)]
+		p.cursor++
+	}
+
+	selected.inflight++
+	selected.uses++
+	selected.lastUsed = now
+	return selected, nil
+}
+
+// pickWeighted 平滑加权轮询
+func (p *Pool) pickWeighted(available []*Key) *Key {
+	total := 0
+	var selected *Key
+	for _, k := range available {
+		k.currentWeight += k.weight
+		total += k.weight
+		if selected == nil || k.currentWeight > selected.currentWeight {
+			selected = k
+		}
+	}
+	selected.currentWeight -= total
+	return selected
+}
+
+// pickLeastUsed 选择并发最少的 key, 并发相同时选择累计使用次数最少的
+func (p *Pool) pickLeastUsed(available []*Key) *Key {
+	selected := available[0]
+	for _, k := range available[1:] {
+		if k.inflight < selected.inflight || (k.inflight == selected.inflight && k.uses < selected.uses) {
+			selected = k
+		}
+	}
+	return selected
+}
+
+// Report 上报 key 的请求结果, status 为 0 表示请求未得到上游响应
+// 401/402/429 会使 key 进入指数退避, 期间不会再被选中
+func (p *Pool) Report(ctx context.Context, k *Key, status int) {
+	if k == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k.inflight > 0 {
+		k.inflight--
+	}
+	if status == 0 {
+		return
+	}
+	k.lastStatus = status
+
+	switch status {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusTooManyRequests:
+		k.failures++
+		backoff := backoffBase() << (k.failures - 1)
+		if backoff > maxBackoff || backoff <= 0 {
+			backoff = maxBackoff
+		}
+		k.exhaustedUntil = time.Now().Add(backoff)
+		slog.WarnContext(ctx, "key backing off", "key", mask(k.value), "pool", p.name, "status", status, "backoff", backoff)
+	default:
+		if status < http.StatusBadRequest {
+			k.failures = 0
+		}
+	}
+}
+
+// backoffBase key 首次退避的时间
+func backoffBase() time.Duration {
+	seconds := config.Current().Upstream.KeyPoolBackoff
+	if seconds <= 0 {
+		return defaultBackoff
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,140 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// acquireN 连续选择 n 次 key 并立即上报成功, 返回选中的 key 序列
+func acquireN(t *testing.T, p *Pool, n int) []string {
+	t.Helper()
+	picked := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		k, err := p.Acquire()
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		picked = append(picked, k.Value())
+		p.Report(context.Background(), k, http.StatusOK)
+	}
+	return picked
+}
+
+func TestStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		keys     []string
+		want     []string
+	}{
+		{"round robin", StrategyRoundRobin, []string{"a", "b", "c"}, []string{"a", "b", "c", "a", "b", "c"}},
+		{"default round robin", "", []string{"a", "b"}, []string{"a", "b", "a", "b"}},
+		{"weighted", StrategyWeighted, []string{"a#3", "b#1"}, []string{"a", "a", "b", "a", "a", "a", "b", "a"}},
+		{"least used", StrategyLeastUsed, []string{"a", "b", "c"}, []string{"a", "b", "c", "a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPool(tt.name, tt.keys, tt.strategy, nil)
+			got := acquireN(t, p, len(tt.want))
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("picked %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLeastUsedPrefersIdleKey(t *testing.T) {
+	p := newPool("least-used-inflight", []string{"a", "b"}, StrategyLeastUsed, nil)
+	busy, _ := p.Acquire()
+	for i := 0; i < 3; i++ {
+		k, err := p.Acquire()
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		if k == busy {
+			t.Fatalf("picked busy key %s while another key is idle", k.Value())
+		}
+		p.Report(context.Background(), k, http.StatusOK)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		exhausted bool
+		backoff   time.Duration
+	}{
+		{"success", []int{http.StatusOK}, false, 0},
+		{"no response", []int{0}, false, 0},
+		{"server error", []int{http.StatusInternalServerError}, false, 0},
+		{"rate limited", []int{http.StatusTooManyRequests}, true, defaultBackoff},
+		{"unauthorized twice", []int{http.StatusUnauthorized, http.StatusUnauthorized}, true, 2 * defaultBackoff},
+		{"payment required thrice", []int{http.StatusPaymentRequired, http.StatusPaymentRequired, http.StatusPaymentRequired}, true, 4 * defaultBackoff},
+		{"capped", []int{429, 429, 429, 429, 429, 429, 429, 429}, true, maxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPool(tt.name, []string{"a"}, "", nil)
+			k := p.keys[0]
+			for _, status := range tt.statuses {
+				// 退避期间无法选中, 直接上报结果
+				p.Report(context.Background(), k, status)
+			}
+
+			_, err := p.Acquire()
+			if exhausted := errors.Is(err, ErrExhausted); exhausted != tt.exhausted {
+				t.Fatalf("Acquire error = %v, want exhausted %v", err, tt.exhausted)
+			}
+			if !tt.exhausted {
+				return
+			}
+			remaining := time.Until(k.exhaustedUntil)
+			if remaining > tt.backoff || remaining < tt.backoff-time.Second {
+				t.Fatalf("backoff = %v, want %v", remaining, tt.backoff)
+			}
+		})
+	}
+}
+
+func TestExhaustedKeyIsSkipped(t *testing.T) {
+	p := newPool("skip-exhausted", []string{"a", "b"}, StrategyRoundRobin, nil)
+	k, _ := p.Acquire()
+	p.Report(context.Background(), k, http.StatusTooManyRequests)
+
+	for _, picked := range acquireN(t, p, 3) {
+		if picked == k.Value() {
+			t.Fatalf("picked exhausted key %s", picked)
+		}
+	}
+}
+
+func TestGetKeepsStateOnReload(t *testing.T) {
+	p := Get("reload", []string{"a", "b"}, StrategyRoundRobin)
+	k, _ := p.Acquire()
+	p.Report(context.Background(), k, http.StatusTooManyRequests)
+
+	if same := Get("reload", []string{"a", "b"}, StrategyRoundRobin); same != p {
+		t.Fatalf("Get returned a new pool for the same keys")
+	}
+	reloaded := Get("reload", []string{"b", "a#2"}, StrategyRoundRobin)
+	if reloaded == p {
+		t.Fatalf("Get returned the old pool for changed keys")
+	}
+	for _, key := range reloaded.keys {
+		if key.value == "a" && !time.Now().Before(key.exhaustedUntil) {
+			t.Fatalf("backoff of key a lost on reload")
+		}
+	}
+}
+
+func TestEmptyPool(t *testing.T) {
+	if _, err := newPool("empty", nil, "", nil).Acquire(); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("Acquire error = %v, want ErrEmpty", err)
+	}
+}
@@ -0,0 +1,267 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector 可以按 Prometheus 文本格式输出的指标
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+// register 注册指标, 输出时按注册顺序排列
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// WriteTo 按 Prometheus 文本格式输出所有指标
+func WriteTo(w io.Writer) error {
+	registryMu.Lock()
+	list := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range list {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler Prometheus 抓取接口
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteTo(w)
+	})
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounterVec 创建并注册计数器
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	v := &CounterVec{name: name, help: help, labels: labels, series: make(map[string]*counterSeries)}
+	register(v)
+	return v
+}
+
+// Inc 计数加 1, values 与创建时的标签一一对应
+func (v *CounterVec) Inc(values ...string) {
+	v.Add(1, values...)
+}
+
+// Add 计数增加 n
+func (v *CounterVec) Add(n float64, values ...string) {
+	checkLabels(v.name, v.labels, values)
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &counterSeries{values: values}
+		v.series[key] = s
+	}
+	s.value += n
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.series) == 0 {
+		return
+	}
+
+	writeHeader(w, v.name, v.help, "counter")
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		writeSample(w, v.name, v.labels, s.values, "", "", s.value)
+	}
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // 每个桶的计数, 未累加
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec 创建并注册直方图, buckets 为升序的桶上限
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	register(v)
+	return v
+}
+
+// Observe 记录一次观测值
+func (v *HistogramVec) Observe(value float64, values ...string) {
+	checkLabels(v.name, v.labels, values)
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &histogramSeries{values: values, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	if i := sort.SearchFloat64s(v.buckets, value); i < len(v.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.series) == 0 {
+		return
+	}
+
+	writeHeader(w, v.name, v.help, "histogram")
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, v.name+"_bucket", v.labels, s.values, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, v.name+"_bucket", v.labels, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, v.name+"_sum", v.labels, s.values, "", "", s.sum)
+		writeSample(w, v.name+"_count", v.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+// funcMetric 抓取时才计算取值的无标签指标, 如缓存命中次数
+type funcMetric struct {
+	name string
+	help string
+	typ  string
+	fn   func() (float64, bool)
+}
+
+// NewCounterFunc 注册抓取时计算的计数器, fn 返回 false 时不输出
+func NewCounterFunc(name string, help string, fn func() (float64, bool)) {
+	register(&funcMetric{name: name, help: help, typ: "counter", fn: fn})
+}
+
+// NewGaugeFunc 注册抓取时计算的仪表盘, fn 返回 false 时不输出
+func NewGaugeFunc(name string, help string, fn func() (float64, bool)) {
+	register(&funcMetric{name: name, help: help, typ: "gauge", fn: fn})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	value, ok := m.fn()
+	if !ok {
+		return
+	}
+	writeHeader(w, m.name, m.help, m.typ)
+	writeSample(w, m.name, nil, nil, "", "", value)
+}
+
+// checkLabels 标签数量不一致属于编码错误
+func checkLabels(name string, labels []string, values []string) {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", name, len(labels), len(values)))
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeSample 输出一行样本, extraName 不为空时追加一个标签 (直方图的 le)
+func writeSample(w *bufio.Writer, name string, labels []string, values []string, extraName string, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabel(values[i]))
+			w.WriteByte('"')
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName)
+			w.WriteString(`="`)
+			w.WriteString(extraValue)
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
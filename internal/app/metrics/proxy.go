@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"ripper/internal/cache"
+	"strconv"
+)
+
+// 代理请求相关指标, route 为处理函数名称, 如 CodeCompletions、ChatCompletions、HandleEmbeddings、HandleChunks
+var (
+	Requests = NewCounterVec("copilot_proxy_requests_total",
+		"Total number of proxied requests.", "route", "provider", "model", "status")
+	RequestDuration = NewHistogramVec("copilot_proxy_request_duration_seconds",
+		"Duration of proxied requests in seconds.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}, "route", "provider", "model")
+	StreamFirstByte = NewHistogramVec("copilot_proxy_stream_first_byte_seconds",
+		"Time to first byte of streamed responses in seconds.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30}, "route", "provider", "model")
+	DebounceCancelled = NewCounterVec("copilot_proxy_debounce_cancelled_total",
+		"Total number of completion requests cancelled by the client during debounce.", "route")
+	Tokens = NewCounterVec("copilot_proxy_tokens_total",
+		"Total number of tokens used, type is prompt, completion or cached (prompt tokens served from the upstream prompt cache).",
+		"route", "provider", "model", "type")
+	UpstreamErrors = NewCounterVec("copilot_proxy_upstream_errors_total",
+		"Total number of failed upstream requests, code is the HTTP status or \"error\" for connection failures.", "provider", "code")
+)
+
+func init() {
+	NewCounterFunc("copilot_proxy_cache_hits_total", "Total number of memory cache hits.", func() (float64, bool) {
+		stats, ok := cache.Stats()
+		return float64(stats.Hits), ok
+	})
+	NewCounterFunc("copilot_proxy_cache_misses_total", "Total number of memory cache misses.", func() (float64, bool) {
+		stats, ok := cache.Stats()
+		return float64(stats.Misses), ok
+	})
+	NewGaugeFunc("copilot_proxy_cache_hit_ratio", "Memory cache hit ratio since startup.", func() (float64, bool) {
+		stats, ok := cache.Stats()
+		if !ok || stats.Hits+stats.Misses == 0 {
+			return 0, false
+		}
+		return float64(stats.Hits) / float64(stats.Hits+stats.Misses), true
+	})
+}
+
+// ObserveUpstream 记录上游请求结果, 连接错误及 4xx、5xx 响应计为上游错误, 客户端主动取消的请求不计入
+func ObserveUpstream(provider string, status int, err error) {
+	switch {
+	case err != nil:
+		if !errors.Is(err, context.Canceled) {
+			UpstreamErrors.Inc(provider, "error")
+		}
+	case status >= 400:
+		UpstreamErrors.Inc(provider, strconv.Itoa(status))
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// file models.json 文件结构
+type file struct {
+	Data []interface{} `json:"data"`
+}
+
+// current 当前生效的模型列表, 热加载时整体替换
+var current atomic.Pointer[[]interface{}]
+
+// Init 加载模型列表文件
+func Init(path string) error {
+	data, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Set(data)
+	return nil
+}
+
+// Load 从文件加载模型列表, 文件不存在时返回 nil
+func Load(path string) ([]interface{}, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read models file %s: %v", path, err)
+	}
+
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse models file %s: %v", path, err)
+	}
+	if f.Data == nil {
+		f.Data = []interface{}{}
+	}
+	return f.Data, nil
+}
+
+// Set 替换当前生效的模型列表
+func Set(data []interface{}) {
+	current.Store(&data)
+}
+
+// List 获取当前生效的模型列表, 未加载时返回 false
+func List() ([]interface{}, bool) {
+	data := current.Load()
+	if data == nil || *data == nil {
+		return nil, false
+	}
+	return *data, true
+}
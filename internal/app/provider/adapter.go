@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/tidwall/sjson"
+)
+
+// StreamWriter 流式响应输出, gin.ResponseWriter 满足该接口
+type StreamWriter interface {
+	io.Writer
+	Flush()
+}
+
+// Adapter 对话接口协议适配器, 负责在 OpenAI 格式与上游协议之间转换
+type Adapter interface {
+	// BuildRequest 将 OpenAI 格式的对话请求体转换为上游请求
+	BuildRequest(ctx context.Context, u *Upstream, key string, body []byte) (*http.Request, error)
+	// Stream 将上游的成功响应转换为 chat.completion.chunk SSE 写入 w
+	Stream(w StreamWriter, resp *http.Response, model string) error
+}
+
+// chatAdapters 支持对话接口的协议类型
+var chatAdapters = map[string]Adapter{
+	TypeOpenAI:    openaiAdapter{},
+	TypeAnthropic: anthropicAdapter{},
+	TypeGemini:    geminiAdapter{},
+	TypeOllama:    ollamaAdapter{},
+	TypeResponses: responsesAdapter{},
+	TypeAzure:     azureAdapter{},
+}
+
+// ChatAdapter 获取提供方对应的对话协议适配器
+func ChatAdapter(p *Provider) Adapter {
+	if adapter, ok := chatAdapters[p.Type]; ok {
+		return adapter
+	}
+	return openaiAdapter{}
+}
+
+// chatTypes 支持对话接口的协议类型列表
+func chatTypes() []string {
+	types := make([]string, 0, len(chatAdapters))
+	for t := range chatAdapters {
+		types = append(types, t)
+	}
+	return types
+}
+
+// openaiAdapter OpenAI 兼容接口, 请求和响应均原样转发
+// 流式请求会要求上游返回 usage chunk 用于统计用量, 客户端未请求时不转发该 chunk
+type openaiAdapter struct{}
+
+func (openaiAdapter) BuildRequest(ctx context.Context, u *Upstream, key string, body []byte) (*http.Request, error) {
+	body, _ = sjson.SetBytes(body, "model", u.Model)
+	body, requested := IncludeUsage(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.Provider.URL(u.Model, "chat/completions"), io.NopCloser(bytes.NewBuffer(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	u.Provider.SetAuth(req, key)
+	if !requested {
+		req = withStripUsage(req)
+	}
+	return req, nil
+}
+
+func (openaiAdapter) Stream(w StreamWriter, resp *http.Response, model string) error {
+	return CopyStream(w, resp.Body, stripUsage(resp))
+}
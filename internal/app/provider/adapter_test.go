@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// adapterCase 一次经 httptest 上游完成的对话转换
+type adapterCase struct {
+	name     string
+	body     string            // 客户端的 OpenAI 格式请求体
+	response string            // 上游返回的响应
+	want     map[string]string // 上游请求体的 gjson 路径 -> 期望值
+	output   []string          // 转换后的输出需要包含的内容
+	absent   []string          // 转换后的输出不应包含的内容
+	err      bool              // 上游在流中返回错误, 输出不应以 [DONE] 结束
+}
+
+// runAdapterCases 使用 typ 类型的适配器请求 httptest 上游, 校验上游收到的请求和转换后的输出
+// path 为上游期望的请求路径, authHeader 为携带 key 的请求头
+func runAdapterCases(t *testing.T, typ string, path string, authHeader string, tests []adapterCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reqPath, auth string
+			var reqBody []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reqPath, auth = r.URL.Path, r.Header.Get(authHeader)
+				reqBody, _ = io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = io.WriteString(w, tt.response)
+			}))
+			defer srv.Close()
+
+			u := &Upstream{
+				Provider: &Provider{Name: "test", Type: typ, APIBase: srv.URL, APIVersion: defaultAzureAPIVersion},
+				Model:    "upstream-model",
+			}
+			adapter := chatAdapters[typ]
+			req, err := adapter.BuildRequest(context.Background(), u, "test-key", []byte(tt.body))
+			if err != nil {
+				t.Fatalf("BuildRequest: %v", err)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			defer resp.Body.Close()
+
+			w := &recordWriter{}
+			err = adapter.Stream(w, resp, "client-model")
+			if (err != nil) != tt.err {
+				t.Fatalf("Stream error = %v, want error %v", err, tt.err)
+			}
+
+			if reqPath != path {
+				t.Errorf("path = %s, want %s", reqPath, path)
+			}
+			if !strings.Contains(auth, "test-key") {
+				t.Errorf("%s = %q, want test-key", authHeader, auth)
+			}
+			for key, want := range tt.want {
+				if got := gjson.GetBytes(reqBody, key).String(); got != want {
+					t.Errorf("request %s = %s, want %s\nbody: %s", key, got, want, reqBody)
+				}
+			}
+			out := w.String()
+			for _, want := range tt.output {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %s\noutput: %s", want, out)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("output contains %s\noutput: %s", unwanted, out)
+				}
+			}
+			if done := strings.HasSuffix(out, "data: [DONE]\n\n"); done == tt.err {
+				t.Errorf("output ends with [DONE] = %v, want %v\noutput: %s", done, !tt.err, out)
+			}
+		})
+	}
+}
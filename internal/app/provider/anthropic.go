@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+)
+
+// anthropicVersion Messages API 版本
+const anthropicVersion = "2023-06-01"
+
+// anthropicAdapter Anthropic Messages API
+type anthropicAdapter struct{}
+
+func (anthropicAdapter) BuildRequest(ctx context.Context, u *Upstream, key string, body []byte) (*http.Request, error) {
+	payload := map[string]interface{}{
+		"model":      u.Model,
+		"max_tokens": maxTokens(body),
+		"stream":     true,
+	}
+
+	system, messages := anthropicMessages(gjson.GetBytes(body, "messages"))
+	if system != "" {
+		payload["system"] = system
+	}
+	payload["messages"] = messages
+
+	if temperature := gjson.GetBytes(body, "temperature"); temperature.Exists() {
+		// Anthropic 的 temperature 取值范围为 0~1
+		payload["temperature"] = min(temperature.Float(), 1)
+	}
+	if topP := gjson.GetBytes(body, "top_p"); topP.Exists() {
+		payload["top_p"] = topP.Float()
+	}
+	if stop := stopSequences(body); len(stop) > 0 {
+		payload["stop_sequences"] = stop
+	}
+
+	toolChoice := gjson.GetBytes(body, "tool_choice")
+	tools := anthropicTools(gjson.GetBytes(body, "tools"))
+	if len(tools) > 0 && toolChoice.String() != "none" {
+		payload["tools"] = tools
+		if choice := anthropicToolChoice(toolChoice); choice != nil {
+			payload["tool_choice"] = choice
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, appendPath(u.Provider.APIBase, "/v1/messages"), io.NopCloser(bytes.NewBuffer(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", key)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
+
+// anthropicMessages 转换对话消息, system 消息单独提取, 相邻的同角色消息合并
+func anthropicMessages(messages gjson.Result) (string, []map[string]interface{}) {
+	var system string
+	result := make([]map[string]interface{}, 0)
+
+	appendBlocks := func(role string, blocks []map[string]interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1]["role"] == role {
+			result[n-1]["content"] = append(result[n-1]["content"].([]map[string]interface{}), blocks...)
+			return
+		}
+		result = append(result, map[string]interface{}{"role": role, "content": blocks})
+	}
+
+	for _, msg := range messages.Array() {
+		switch msg.Get("role").String() {
+		case "system", "developer":
+			if system != "" {
+				system += "\n\n"
+			}
+			system += contentText(msg.Get("content"))
+		case "assistant":
+			blocks := make([]map[string]interface{}, 0)
+			if text := contentText(msg.Get("content")); text != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+			}
+			for _, call := range msg.Get("tool_calls").Array() {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.Get("id").String(),
+					"name":  call.Get("function.name").String(),
+					"input": toolArguments(call.Get("function.arguments").String()),
+				})
+			}
+			appendBlocks("assistant", blocks)
+		case "tool":
+			appendBlocks("user", []map[string]interface{}{{
+				"type":        "tool_result",
+				"tool_use_id": msg.Get("tool_call_id").String(),
+				"content":     contentText(msg.Get("content")),
+			}})
+		default:
+			appendBlocks("user", anthropicUserBlocks(msg.Get("content")))
+		}
+	}
+	return system, result
+}
+
+// anthropicUserBlocks 转换用户消息内容, 支持文本和图片
+func anthropicUserBlocks(content gjson.Result) []map[string]interface{} {
+	if !content.IsArray() {
+		if content.String() == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"type": "text", "text": content.String()}}
+	}
+
+	blocks := make([]map[string]interface{}, 0)
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "text":
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": part.Get("text").String()})
+		case "image_url":
+			url := part.Get("image_url.url").String()
+			source := map[string]interface{}{"type": "url", "url": url}
+			if mediaType, data, ok := parseDataURL(url); ok {
+				source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data}
+			}
+			blocks = append(blocks, map[string]interface{}{"type": "image", "source": source})
+		}
+	}
+	return blocks
+}
+
+// anthropicTools 转换工具定义
+func anthropicTools(tools gjson.Result) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0)
+	for _, tool := range tools.Array() {
+		function := tool.Get("function")
+		if !function.Exists() {
+			continue
+		}
+		schema := function.Get("parameters").Value()
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		result = append(result, map[string]interface{}{
+			"name":         function.Get("name").String(),
+			"description":  function.Get("description").String(),
+			"input_schema": schema,
+		})
+	}
+	return result
+}
+
+// anthropicToolChoice 转换工具选择方式
+func anthropicToolChoice(choice gjson.Result) map[string]interface{} {
+	if choice.IsObject() {
+		return map[string]interface{}{"type": "tool", "name": choice.Get("function.name").String()}
+	}
+	switch choice.String() {
+	case "auto":
+		return map[string]interface{}{"type": "auto"}
+	case "required":
+		return map[string]interface{}{"type": "any"}
+	}
+	return nil
+}
+
+// anthropicStopReason 停止原因与 OpenAI finish_reason 的对应关系
+var anthropicStopReason = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+}
+
+func (anthropicAdapter) Stream(w StreamWriter, resp *http.Response, model string) error {
+	var cw *chunkWriter
+	var inputTokens int64
+	// content block 下标与工具调用下标的对应关系
+	toolIndex := make(map[int64]int)
+
+	var writeErr, streamErr error
+	err := readSSE(resp.Body, func(ev sseEvent) bool {
+		data := gjson.Parse(ev.Data)
+		if cw == nil {
+			cw = newChunkWriter(w, data.Get("message.id").String(), model)
+		}
+
+		switch data.Get("type").String() {
+		case "message_start":
+			inputTokens = data.Get("message.usage.input_tokens").Int()
+			writeErr = cw.delta(map[string]interface{}{"role": "assistant", "content": ""}, "")
+		case "content_block_start":
+			block := data.Get("content_block")
+			if block.Get("type").String() == "tool_use" {
+				index := len(toolIndex)
+				toolIndex[data.Get("index").Int()] = index
+				writeErr = cw.toolCall(index, block.Get("id").String(), block.Get("name").String(), "")
+			}
+		case "content_block_delta":
+			delta := data.Get("delta")
+			switch delta.Get("type").String() {
+			case "text_delta":
+				writeErr = cw.content(delta.Get("text").String())
+			case "input_json_delta":
+				writeErr = cw.toolCall(toolIndex[data.Get("index").Int()], "", "", delta.Get("partial_json").String())
+			}
+		case "message_delta":
+			reason, ok := anthropicStopReason[data.Get("delta.stop_reason").String()]
+			if !ok {
+				reason = "stop"
+			}
+			if writeErr = cw.delta(map[string]interface{}{}, reason); writeErr == nil {
+				writeErr = cw.usage(inputTokens, data.Get("usage.output_tokens").Int())
+			}
+		case "message_stop":
+			return false
+		case "error":
+			streamErr = &StreamError{Provider: TypeAnthropic, Message: data.Get("error.message").String()}
+			return false
+		}
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	if cw == nil {
+		cw = newChunkWriter(w, "", model)
+	}
+	return cw.done()
+}
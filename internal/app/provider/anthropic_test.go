@@ -0,0 +1,59 @@
+package provider
+
+import "testing"
+
+func TestAnthropicAdapter(t *testing.T) {
+	runAdapterCases(t, TypeAnthropic, "/v1/messages", "x-api-key", []adapterCase{
+		{
+			name: "text",
+			body: `{"model":"claude","temperature":1.5,"stop":"END","messages":[
+				{"role":"system","content":"be brief"},
+				{"role":"user","content":"hi"},
+				{"role":"user","content":[{"type":"text","text":"again"}]}
+			]}`,
+			response: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":10}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"max_tokens\"},\"usage\":{\"output_tokens\":5}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			want: map[string]string{
+				"model":                     "upstream-model",
+				"system":                    "be brief",
+				"temperature":               "1",
+				"stop_sequences.0":          "END",
+				"messages.#":                "1",
+				"messages.0.content.#":      "2",
+				"messages.0.content.1.text": "again",
+			},
+			output: []string{`"id":"msg_1"`, `"content":"hello"`, `"finish_reason":"length"`, `"prompt_tokens":10`, `"completion_tokens":5`},
+		},
+		{
+			name: "tool call",
+			body: `{"messages":[
+				{"role":"user","content":"weather?"},
+				{"role":"assistant","tool_calls":[{"id":"call_1","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"}
+			],"tools":[{"type":"function","function":{"name":"weather","parameters":{"type":"object"}}}],"tool_choice":"required"}`,
+			response: "data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\",\"usage\":{\"input_tokens\":3}}}\n\n" +
+				"data: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"weather\"}}\n\n" +
+				"data: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n" +
+				"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":2}}\n\n" +
+				"data: {\"type\":\"message_stop\"}\n\n",
+			want: map[string]string{
+				"messages.1.content.0.type":        "tool_use",
+				"messages.1.content.0.input.city":  "Paris",
+				"messages.2.content.0.type":        "tool_result",
+				"messages.2.content.0.tool_use_id": "call_1",
+				"tools.0.name":                     "weather",
+				"tool_choice.type":                 "any",
+			},
+			output: []string{`"id":"toolu_1"`, `"name":"weather"`, `"finish_reason":"tool_calls"`},
+		},
+		{
+			name: "error event",
+			body: `{"messages":[{"role":"user","content":"hi"}]}`,
+			response: "data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_3\"}}\n\n" +
+				"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			err: true,
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"ripper/internal/config"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// defaultAzureAPIVersion 未配置 AZURE_API_VERSION 时使用的接口版本
+const defaultAzureAPIVersion = "2024-10-21"
+
+// azureURL 拼接 Azure OpenAI 部署接口地址
+// api_base 为资源地址时按 /openai/deployments/{deployment}/{operation} 拼接, 已是完整部署地址时原样使用
+func azureURL(base string, deployment string, operation string, apiVersion string) string {
+	if apiVersion == "" {
+		apiVersion = config.Current().Upstream.AzureAPIVersion
+	}
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+
+	base = strings.TrimRight(base, "/")
+	if !strings.Contains(base, "/openai/deployments/") {
+		base += "/openai/deployments/" + url.PathEscape(deployment) + "/" + operation
+	}
+	if strings.Contains(base, "api-version=") {
+		return base
+	}
+	if strings.Contains(base, "?") {
+		return base + "&api-version=" + url.QueryEscape(apiVersion)
+	}
+	return base + "?api-version=" + url.QueryEscape(apiVersion)
+}
+
+// ContentFilterMessage 判断上游错误响应是否为 Azure 内容过滤, 返回过滤原因
+func ContentFilterMessage(body []byte) (string, bool) {
+	errResult := gjson.GetBytes(body, "error")
+	if errResult.Get("code").String() != "content_filter" &&
+		errResult.Get("innererror.code").String() != "ResponsibleAIPolicyViolation" {
+		return "", false
+	}
+
+	message := errResult.Get("message").String()
+	if message == "" {
+		message = "The response was filtered due to the prompt triggering content management policy."
+	}
+	return message, true
+}
+
+// WriteContentFilter 以 finish_reason 为 content_filter 的 chunk 结束对话流
+func WriteContentFilter(w StreamWriter, model string, message string) error {
+	cw := newChunkWriter(w, "", model)
+	if err := cw.content(message); err != nil {
+		return err
+	}
+	if err := cw.delta(map[string]interface{}{}, "content_filter"); err != nil {
+		return err
+	}
+	return cw.done()
+}
+
+// azureAdapter Azure OpenAI 部署接口, 请求格式与 OpenAI 一致
+type azureAdapter struct {
+	openaiAdapter
+}
+
+// Stream 转发上游响应, 丢弃只包含 prompt_filter_results 的空 chunk, 避免客户端解析失败
+// 客户端未请求 usage 时, usage chunk 只用于统计用量, 不转发
+func (azureAdapter) Stream(w StreamWriter, resp *http.Response, model string) error {
+	strip := stripUsage(resp)
+	var writeErr error
+	err := readSSE(resp.Body, func(ev sseEvent) bool {
+		if ev.Data != "[DONE]" {
+			data := gjson.Parse(ev.Data)
+			if len(data.Get("choices").Array()) == 0 && !data.Get("usage").IsObject() {
+				return true
+			}
+			if strip && isUsageChunk(data) {
+				observeUsage(w, ev.Data)
+				return true
+			}
+		}
+		if _, writeErr = io.WriteString(w, "data: "+ev.Data+"\n\n"); writeErr != nil {
+			return false
+		}
+		w.Flush()
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
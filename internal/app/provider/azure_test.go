@@ -0,0 +1,48 @@
+package provider
+
+import "testing"
+
+func TestAzureAdapter(t *testing.T) {
+	response := "data: {\"choices\":[],\"prompt_filter_results\":[{\"prompt_index\":0}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello\"}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1}}\n\n" +
+		"data: [DONE]\n\n"
+
+	runAdapterCases(t, TypeAzure, "/openai/deployments/upstream-model/chat/completions", "api-key", []adapterCase{
+		{
+			name:     "usage not requested",
+			body:     `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+			response: response,
+			want: map[string]string{
+				"model":                        "upstream-model",
+				"stream_options.include_usage": "true",
+			},
+			output: []string{`"content":"hello"`},
+			absent: []string{"prompt_filter_results", "usage"},
+		},
+		{
+			name:     "usage requested",
+			body:     `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`,
+			response: response,
+			want:     map[string]string{"stream_options.include_usage": "true"},
+			output:   []string{`"content":"hello"`, `"prompt_tokens":5`},
+			absent:   []string{"prompt_filter_results"},
+		},
+	})
+}
+
+func TestAzureURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://res.openai.azure.com", "https://res.openai.azure.com/openai/deployments/gpt%2F4o/chat/completions?api-version=v1"},
+		{"https://res.openai.azure.com/openai/deployments/other/chat/completions", "https://res.openai.azure.com/openai/deployments/other/chat/completions?api-version=v1"},
+		{"https://res.openai.azure.com/openai/deployments/other/chat/completions?api-version=v2", "https://res.openai.azure.com/openai/deployments/other/chat/completions?api-version=v2"},
+	}
+	for _, tt := range tests {
+		if got := azureURL(tt.base, "gpt/4o", "chat/completions", "v1"); got != tt.want {
+			t.Errorf("azureURL(%s) = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
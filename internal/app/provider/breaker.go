@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"log/slog"
+	"ripper/internal/config"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// breaker 单个上游的熔断器
+// 连续失败达到阈值后熔断, 冷却期内直接跳过该上游; 冷却结束后放行一个探测请求
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+var breakers sync.Map
+
+// getBreaker 获取上游对应的熔断器
+func getBreaker(name string) *breaker {
+	b, _ := breakers.LoadOrStore(name, &breaker{})
+	return b.(*breaker)
+}
+
+// breakerThreshold 连续失败多少次后熔断
+func breakerThreshold() int {
+	threshold := config.Current().Upstream.CircuitBreakerThreshold
+	if threshold <= 0 {
+		return defaultBreakerThreshold
+	}
+	return threshold
+}
+
+// breakerCooldown 熔断后的冷却时间
+func breakerCooldown() time.Duration {
+	seconds := config.Current().Upstream.CircuitBreakerCooldown
+	if seconds <= 0 {
+		return defaultBreakerCooldown
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// allow 判断是否允许请求该上游
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	// 冷却结束, 放行一个探测请求
+	b.probing = true
+	return true
+}
+
+// success 记录一次成功请求, 关闭熔断
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+}
+
+// release 未发出请求时归还探测名额
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// failure 记录一次失败请求, 达到阈值时熔断
+func (b *breaker) failure(ctx context.Context, name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures >= breakerThreshold() {
+		cooldown := breakerCooldown()
+		b.openUntil = time.Now().Add(cooldown)
+		slog.WarnContext(ctx, "upstream tripped circuit breaker", "upstream", name, "failures", b.failures, "cooldown", cooldown)
+	}
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"context"
+	"ripper/internal/config"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	cfg := config.Default()
+	cfg.Upstream.CircuitBreakerThreshold = 3
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	// allow/deny 断言 allow() 的结果, expire 模拟冷却结束
+	tests := []struct {
+		name  string
+		steps []string
+	}{
+		{"below threshold", []string{"fail", "fail", "allow", "allow"}},
+		{"open", []string{"fail", "fail", "fail", "deny", "deny"}},
+		{"success resets", []string{"fail", "fail", "success", "fail", "fail", "allow"}},
+		{"half open single probe", []string{"fail", "fail", "fail", "expire", "allow", "deny"}},
+		{"probe success closes", []string{"fail", "fail", "fail", "expire", "allow", "success", "allow", "allow"}},
+		{"probe failure reopens", []string{"fail", "fail", "fail", "expire", "allow", "fail", "deny"}},
+		{"release returns probe", []string{"fail", "fail", "fail", "expire", "allow", "release", "allow"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &breaker{}
+			for i, step := range tt.steps {
+				switch step {
+				case "fail":
+					b.failure(context.Background(), tt.name)
+				case "success":
+					b.success()
+				case "release":
+					b.release()
+				case "expire":
+					b.openUntil = time.Now().Add(-time.Second)
+				case "allow", "deny":
+					if got := b.allow(); got != (step == "allow") {
+						t.Fatalf("step %d: allow() = %v, want %v", i, got, step == "allow")
+					}
+				}
+			}
+		})
+	}
+}
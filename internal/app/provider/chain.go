@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"ripper/internal/app/keypool"
+	"ripper/internal/app/metrics"
+	"sync"
+)
+
+// ErrNoUpstream 所有上游均不可用
+var ErrNoUpstream = errors.New("no upstream available")
+
+// Chain 按顺序尝试的上游链
+type Chain []*Upstream
+
+// BuildFunc 使用选中的 key 为指定上游构建请求
+type BuildFunc func(u *Upstream, key string) (*http.Request, error)
+
+// Do 按顺序请求上游, 遇到连接错误、429 或 5xx 时切换到下一个上游, 每个上游使用各自的共享客户端
+// 返回的响应可能是最后一个上游的失败响应, 调用方需要自行检查状态码
+// 所选 key 在响应体关闭时才上报结果, 流式响应期间仍计入并发, 调用方必须关闭响应体
+func (c Chain) Do(ctx context.Context, build BuildFunc) (*http.Response, *Upstream, error) {
+	var lastResp *http.Response
+	var lastUpstream *Upstream
+	lastErr := ErrNoUpstream
+
+	for _, u := range c {
+		b := getBreaker(u.Provider.Name)
+		if !b.allow() {
+			continue
+		}
+
+		client, err := u.Provider.Client()
+		if err != nil {
+			slog.ErrorContext(ctx, "create client for upstream failed", "upstream", u.Provider.Name, "error", err)
+			lastErr = err
+			b.release()
+			continue
+		}
+
+		// 未配置 key 的上游 (如本地模型) 不携带 key
+		pool := u.Provider.Pool()
+		var key *keypool.Key
+		if pool.Len() > 0 {
+			key, err = pool.Acquire()
+			if err != nil {
+				slog.WarnContext(ctx, "acquire key for upstream failed", "upstream", u.Provider.Name, "error", err)
+				lastErr = err
+				b.release()
+				continue
+			}
+		}
+
+		keyValue := ""
+		if key != nil {
+			keyValue = key.Value()
+		}
+		req, err := build(u, keyValue)
+		if err != nil {
+			slog.ErrorContext(ctx, "build request for upstream failed", "upstream", u.Provider.Name, "error", err)
+			pool.Report(ctx, key, 0)
+			lastErr = err
+			b.release()
+			continue
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			pool.Report(ctx, key, 0)
+			metrics.ObserveUpstream(u.Provider.Name, 0, err)
+			if errors.Is(err, context.Canceled) {
+				b.release()
+				return nil, u, err
+			}
+			slog.WarnContext(ctx, "request upstream failed", "upstream", u.Provider.Name, "error", err)
+			b.failure(ctx, u.Provider.Name)
+			lastErr = err
+			continue
+		}
+		metrics.ObserveUpstream(u.Provider.Name, resp.StatusCode, nil)
+
+		if !shouldFailover(resp.StatusCode) {
+			b.success()
+			resp.Body = &reportBody{ReadCloser: resp.Body, ctx: ctx, pool: pool, key: key, status: resp.StatusCode}
+			return resp, u, nil
+		}
+		pool.Report(ctx, key, resp.StatusCode)
+
+		// 缓存失败响应, 所有上游都失败时返回给调用方
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewBuffer(body))
+		slog.WarnContext(ctx, "upstream responded with error", "upstream", u.Provider.Name, "status", resp.StatusCode, "body", string(body))
+
+		b.failure(ctx, u.Provider.Name)
+		lastResp, lastUpstream = resp, u
+	}
+
+	if lastResp != nil {
+		return lastResp, lastUpstream, nil
+	}
+	return nil, nil, fmt.Errorf("all upstreams failed: %w", lastErr)
+}
+
+// reportBody 在响应体关闭时向 key 池上报结果
+type reportBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	pool   *keypool.Pool
+	key    *keypool.Key
+	status int
+	once   sync.Once
+}
+
+func (b *reportBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.pool.Report(b.ctx, b.key, b.status) })
+	return err
+}
+
+// shouldFailover 判断响应状态码是否需要切换上游
+func shouldFailover(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
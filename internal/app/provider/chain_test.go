@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ripper/internal/app/keypool"
+	"testing"
+)
+
+// inflight 获取提供方 key 池中 key 的并发数
+func inflight(t *testing.T, name string) int {
+	t.Helper()
+	for _, pool := range keypool.Snapshot() {
+		if pool.Name == "provider:"+name {
+			return pool.Keys[0].Inflight
+		}
+	}
+	t.Fatalf("key pool of %s not found", name)
+	return 0
+}
+
+func TestChainReportsKeyOnBodyClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	p := &Provider{Name: "report-on-close", APIBase: srv.URL, APIKeys: []string{"key-1"}}
+	resp, _, err := Chain{{Provider: p}}.Do(context.Background(), func(u *Upstream, key string) (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, u.Provider.APIBase, nil)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	if n := inflight(t, p.Name); n != 1 {
+		t.Fatalf("inflight before close = %d, want 1", n)
+	}
+	_, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	_ = resp.Body.Close()
+	if n := inflight(t, p.Name); n != 0 {
+		t.Fatalf("inflight after close = %d, want 0", n)
+	}
+}
+
+func TestChainFailover(t *testing.T) {
+	// 已关闭的服务地址, 请求时返回连接错误
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		statuses []int // 各上游返回的状态码, -1 表示连接错误
+		hits     []int // 各上游期望收到的请求数
+		status   int   // 期望返回的状态码, 0 表示返回错误
+		upstream int   // 期望返回的上游下标
+	}{
+		{"success", []int{200, 200}, []int{1, 0}, 200, 0},
+		{"429", []int{429, 200}, []int{1, 1}, 200, 1},
+		{"5xx", []int{502, 200}, []int{1, 1}, 200, 1},
+		{"connection error", []int{-1, 200}, []int{0, 1}, 200, 1},
+		{"4xx is not retried", []int{400, 200}, []int{1, 0}, 400, 0},
+		{"all failed responses", []int{500, 503}, []int{1, 1}, 503, 1},
+		{"all connection errors", []int{-1, -1}, []int{0, 0}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := make([]int, len(tt.statuses))
+			chain := make(Chain, 0, len(tt.statuses))
+			for i, status := range tt.statuses {
+				i, status := i, status
+				base := closed.URL
+				if status > 0 {
+					srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						hits[i]++
+						w.WriteHeader(status)
+					}))
+					defer srv.Close()
+					base = srv.URL
+				}
+				name := fmt.Sprintf("failover-%s-%d", tt.name, i)
+				chain = append(chain, &Upstream{Provider: &Provider{Name: name, APIBase: base, APIKeys: []string{"key"}}})
+			}
+
+			resp, u, err := chain.Do(context.Background(), func(u *Upstream, key string) (*http.Request, error) {
+				return http.NewRequest(http.MethodPost, u.Provider.APIBase, nil)
+			})
+			if tt.status == 0 {
+				if err == nil {
+					t.Fatalf("Do status = %d, want error", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status || u != chain[tt.upstream] {
+				t.Fatalf("Do = %d from %s, want %d from %s", resp.StatusCode, u.Provider.Name, tt.status, chain[tt.upstream].Provider.Name)
+			}
+			for i := range hits {
+				if hits[i] != tt.hits[i] {
+					t.Fatalf("hits = %v, want %v", hits, tt.hits)
+				}
+			}
+		})
+	}
+}
+
+func TestChainSkipsOpenBreaker(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+
+	open := &Upstream{Provider: &Provider{Name: "breaker-open", APIBase: srv.URL}}
+	healthy := &Upstream{Provider: &Provider{Name: "breaker-healthy", APIBase: srv.URL}}
+	b := getBreaker(open.Provider.Name)
+	for i := 0; i < breakerThreshold(); i++ {
+		b.failure(context.Background(), open.Provider.Name)
+	}
+
+	resp, u, err := Chain{open, healthy}.Do(context.Background(), func(u *Upstream, key string) (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, u.Provider.APIBase, nil)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if u != healthy || hits != 1 {
+		t.Fatalf("Do used %s with %d requests, want breaker-healthy with 1", u.Provider.Name, hits)
+	}
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// defaultMaxTokens 上游协议要求必填 max_tokens 且请求未携带时使用的默认值
+const defaultMaxTokens = 4096
+
+// contentText 提取 OpenAI 消息内容中的文本, 兼容字符串和多段内容两种格式
+func contentText(content gjson.Result) string {
+	if !content.IsArray() {
+		return content.String()
+	}
+
+	var sb strings.Builder
+	for _, part := range content.Array() {
+		if part.Get("type").String() == "text" {
+			sb.WriteString(part.Get("text").String())
+		}
+	}
+	return sb.String()
+}
+
+// parseDataURL 解析 data:<media_type>;base64,<data> 格式的图片地址
+func parseDataURL(url string) (string, string, bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
+
+// maxTokens 获取请求中的最大响应 tokens, 未设置时使用默认值
+func maxTokens(body []byte) int64 {
+	if n := gjson.GetBytes(body, "max_tokens").Int(); n > 0 {
+		return n
+	}
+	return defaultMaxTokens
+}
+
+// stopSequences 获取请求中的停止词, 兼容字符串和数组两种格式
+func stopSequences(body []byte) []string {
+	stop := gjson.GetBytes(body, "stop")
+	if !stop.Exists() {
+		return nil
+	}
+	if !stop.IsArray() {
+		if stop.String() == "" {
+			return nil
+		}
+		return []string{stop.String()}
+	}
+
+	sequences := make([]string, 0)
+	for _, s := range stop.Array() {
+		sequences = append(sequences, s.String())
+	}
+	return sequences
+}
+
+// toolArguments 解析工具调用参数, 非法 JSON 时返回空对象
+func toolArguments(arguments string) interface{} {
+	parsed := gjson.Parse(arguments)
+	if !gjson.Valid(arguments) || !parsed.IsObject() {
+		return map[string]interface{}{}
+	}
+	return parsed.Value()
+}
+
+// appendPath 为基础地址追加接口路径, 已包含该路径时原样返回
+func appendPath(base string, path string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, path) {
+		return base
+	}
+	return base + path
+}
@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/tidwall/gjson"
+)
+
+// geminiAdapter Google Gemini generateContent 接口
+type geminiAdapter struct{}
+
+// geminiUnsupportedSchemaKeys Gemini 函数参数不支持的 JSON Schema 字段
+var geminiUnsupportedSchemaKeys = []string{"$schema", "additionalProperties", "$ref", "$defs", "definitions"}
+
+func (geminiAdapter) BuildRequest(ctx context.Context, u *Upstream, key string, body []byte) (*http.Request, error) {
+	generationConfig := map[string]interface{}{
+		"maxOutputTokens": maxTokens(body),
+	}
+	if temperature := gjson.GetBytes(body, "temperature"); temperature.Exists() {
+		generationConfig["temperature"] = temperature.Float()
+	}
+	if topP := gjson.GetBytes(body, "top_p"); topP.Exists() {
+		generationConfig["topP"] = topP.Float()
+	}
+	if stop := stopSequences(body); len(stop) > 0 {
+		generationConfig["stopSequences"] = stop
+	}
+
+	system, contents := geminiContents(gjson.GetBytes(body, "messages"))
+	payload := map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": generationConfig,
+	}
+	if system != "" {
+		payload["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{{"text": system}},
+		}
+	}
+	if declarations := geminiFunctionDeclarations(gjson.GetBytes(body, "tools")); len(declarations) > 0 {
+		payload["tools"] = []map[string]interface{}{{"functionDeclarations": declarations}}
+		if config := geminiToolConfig(gjson.GetBytes(body, "tool_choice")); config != nil {
+			payload["toolConfig"] = config
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, geminiURL(u.Provider.APIBase, u.Model), io.NopCloser(bytes.NewBuffer(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", key)
+	return req, nil
+}
+
+// geminiURL 拼接流式生成接口地址, api_base 可只填写服务域名
+func geminiURL(base string, model string) string {
+	base = strings.TrimRight(base, "/")
+	if !strings.HasSuffix(base, "/v1beta") && !strings.HasSuffix(base, "/v1") {
+		base += "/v1beta"
+	}
+	return base + "/models/" + model + ":streamGenerateContent?alt=sse"
+}
+
+// geminiContents 转换对话消息, system 消息单独提取, 工具结果按调用 id 找回函数名
+func geminiContents(messages gjson.Result) (string, []map[string]interface{}) {
+	var system string
+	contents := make([]map[string]interface{}, 0)
+	callNames := make(map[string]string)
+
+	appendParts := func(role string, parts []map[string]interface{}) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1]["role"] == role {
+			contents[n-1]["parts"] = append(contents[n-1]["parts"].([]map[string]interface{}), parts...)
+			return
+		}
+		contents = append(contents, map[string]interface{}{"role": role, "parts": parts})
+	}
+
+	for _, msg := range messages.Array() {
+		switch msg.Get("role").String() {
+		case "system", "developer":
+			if system != "" {
+				system += "\n\n"
+			}
+			system += contentText(msg.Get("content"))
+		case "assistant":
+			parts := make([]map[string]interface{}, 0)
+			if text := contentText(msg.Get("content")); text != "" {
+				parts = append(parts, map[string]interface{}{"text": text})
+			}
+			for _, call := range msg.Get("tool_calls").Array() {
+				name := call.Get("function.name").String()
+				callNames[call.Get("id").String()] = name
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{
+						"name": name,
+						"args": toolArguments(call.Get("function.arguments").String()),
+					},
+				})
+			}
+			appendParts("model", parts)
+		case "tool":
+			content := contentText(msg.Get("content"))
+			response := toolArguments(content)
+			if !gjson.Valid(content) || !gjson.Parse(content).IsObject() {
+				response = map[string]interface{}{"content": content}
+			}
+			appendParts("user", []map[string]interface{}{{
+				"functionResponse": map[string]interface{}{
+					"name":     callNames[msg.Get("tool_call_id").String()],
+					"response": response,
+				},
+			}})
+		default:
+			appendParts("user", geminiUserParts(msg.Get("content")))
+		}
+	}
+	return system, contents
+}
+
+// geminiUserParts 转换用户消息内容, 支持文本和 base64 图片
+func geminiUserParts(content gjson.Result) []map[string]interface{} {
+	if !content.IsArray() {
+		if content.String() == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"text": content.String()}}
+	}
+
+	parts := make([]map[string]interface{}, 0)
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "text":
+			parts = append(parts, map[string]interface{}{"text": part.Get("text").String()})
+		case "image_url":
+			url := part.Get("image_url.url").String()
+			if mimeType, data, ok := parseDataURL(url); ok {
+				parts = append(parts, map[string]interface{}{
+					"inlineData": map[string]interface{}{"mimeType": mimeType, "data": data},
+				})
+				continue
+			}
+			parts = append(parts, map[string]interface{}{
+				"fileData": map[string]interface{}{"fileUri": url},
+			})
+		}
+	}
+	return parts
+}
+
+// geminiFunctionDeclarations 转换工具定义
+func geminiFunctionDeclarations(tools gjson.Result) []map[string]interface{} {
+	declarations := make([]map[string]interface{}, 0)
+	for _, tool := range tools.Array() {
+		function := tool.Get("function")
+		if !function.Exists() {
+			continue
+		}
+		declaration := map[string]interface{}{
+			"name":        function.Get("name").String(),
+			"description": function.Get("description").String(),
+		}
+		// 无参数的函数不能携带空的 properties
+		if parameters := function.Get("parameters"); len(parameters.Get("properties").Map()) > 0 {
+			declaration["parameters"] = geminiSchema(parameters.Value())
+		}
+		declarations = append(declarations, declaration)
+	}
+	return declarations
+}
+
+// geminiSchema 递归移除 Gemini 不支持的 JSON Schema 字段
+func geminiSchema(schema interface{}) interface{} {
+	switch v := schema.(type) {
+	case map[string]interface{}:
+		for _, key := range geminiUnsupportedSchemaKeys {
+			delete(v, key)
+		}
+		for key, value := range v {
+			v[key] = geminiSchema(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = geminiSchema(value)
+		}
+	}
+	return schema
+}
+
+// geminiToolConfig 转换工具选择方式
+func geminiToolConfig(choice gjson.Result) map[string]interface{} {
+	config := map[string]interface{}{}
+	switch {
+	case choice.IsObject():
+		config["mode"] = "ANY"
+		config["allowedFunctionNames"] = []string{choice.Get("function.name").String()}
+	case choice.String() == "auto":
+		config["mode"] = "AUTO"
+	case choice.String() == "required":
+		config["mode"] = "ANY"
+	case choice.String() == "none":
+		config["mode"] = "NONE"
+	default:
+		return nil
+	}
+	return map[string]interface{}{"functionCallingConfig": config}
+}
+
+// geminiFinishReason 结束原因与 OpenAI finish_reason 的对应关系
+var geminiFinishReason = map[string]string{
+	"STOP":               "stop",
+	"MAX_TOKENS":         "length",
+	"SAFETY":             "content_filter",
+	"RECITATION":         "content_filter",
+	"BLOCKLIST":          "content_filter",
+	"PROHIBITED_CONTENT": "content_filter",
+	"SPII":               "content_filter",
+}
+
+func (geminiAdapter) Stream(w StreamWriter, resp *http.Response, model string) error {
+	cw := newChunkWriter(w, "", model)
+	var toolCalls int
+	var usage gjson.Result
+
+	var writeErr, streamErr error
+	err := readSSE(resp.Body, func(ev sseEvent) bool {
+		data := gjson.Parse(ev.Data)
+		if errMsg := data.Get("error.message"); errMsg.Exists() {
+			streamErr = &StreamError{Provider: TypeGemini, Message: errMsg.String()}
+			return false
+		}
+		if data.Get("usageMetadata").Exists() {
+			usage = data.Get("usageMetadata")
+		}
+
+		candidate := data.Get("candidates.0")
+		for _, part := range candidate.Get("content.parts").Array() {
+			switch {
+			case part.Get("functionCall").Exists():
+				call := part.Get("functionCall")
+				arguments := call.Get("args").Raw
+				if arguments == "" {
+					arguments = "{}"
+				}
+				writeErr = cw.toolCall(toolCalls, "call_"+uuid.Must(uuid.NewV4()).String(), call.Get("name").String(), arguments)
+				toolCalls++
+			case part.Get("thought").Bool():
+				// 思考内容不输出
+			case part.Get("text").Exists():
+				writeErr = cw.content(part.Get("text").String())
+			}
+			if writeErr != nil {
+				return false
+			}
+		}
+
+		if reason := candidate.Get("finishReason").String(); reason != "" {
+			finishReason, ok := geminiFinishReason[reason]
+			if !ok {
+				finishReason = "stop"
+			}
+			if toolCalls > 0 && finishReason == "stop" {
+				finishReason = "tool_calls"
+			}
+			writeErr = cw.delta(map[string]interface{}{}, finishReason)
+		}
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	if usage.Exists() {
+		if err := cw.usage(usage.Get("promptTokenCount").Int(), usage.Get("candidatesTokenCount").Int()); err != nil {
+			return err
+		}
+	}
+	return cw.done()
+}
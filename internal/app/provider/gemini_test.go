@@ -0,0 +1,51 @@
+package provider
+
+import "testing"
+
+func TestGeminiAdapter(t *testing.T) {
+	runAdapterCases(t, TypeGemini, "/v1beta/models/upstream-model:streamGenerateContent", "x-goog-api-key", []adapterCase{
+		{
+			name: "text",
+			body: `{"max_tokens":100,"top_p":0.5,"messages":[
+				{"role":"system","content":"be brief"},
+				{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}
+			]}`,
+			response: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"thought\":true,\"text\":\"thinking\"},{\"text\":\"hello\"}]}}]}\n\n" +
+				"data: {\"candidates\":[{\"finishReason\":\"MAX_TOKENS\"}],\"usageMetadata\":{\"promptTokenCount\":7,\"candidatesTokenCount\":3}}\n\n",
+			want: map[string]string{
+				"systemInstruction.parts.0.text":         "be brief",
+				"contents.0.role":                        "user",
+				"contents.0.parts.1.inlineData.mimeType": "image/png",
+				"generationConfig.maxOutputTokens":       "100",
+				"generationConfig.topP":                  "0.5",
+			},
+			output: []string{`"content":"hello"`, `"finish_reason":"length"`, `"prompt_tokens":7`, `"completion_tokens":3`},
+		},
+		{
+			name: "function call",
+			body: `{"messages":[
+				{"role":"user","content":"weather?"},
+				{"role":"assistant","tool_calls":[{"id":"call_1","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"}
+			],"tools":[{"type":"function","function":{"name":"weather","parameters":{"type":"object","additionalProperties":false,"properties":{"city":{"type":"string"}}}}}],
+			"tool_choice":{"type":"function","function":{"name":"weather"}}}`,
+			response: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"functionCall\":{\"name\":\"weather\",\"args\":{\"city\":\"Paris\"}}}]},\"finishReason\":\"STOP\"}]}\n\n",
+			want: map[string]string{
+				"contents.1.role": "model",
+				"contents.1.parts.0.functionCall.args.city":                      "Paris",
+				"contents.2.parts.0.functionResponse.name":                       "weather",
+				"contents.2.parts.0.functionResponse.response.content":           "sunny",
+				"tools.0.functionDeclarations.0.parameters.additionalProperties": "",
+				"toolConfig.functionCallingConfig.mode":                          "ANY",
+				"toolConfig.functionCallingConfig.allowedFunctionNames.0":        "weather",
+			},
+			output: []string{`"name":"weather"`, `"arguments":"{\"city\":\"Paris\"}"`, `"finish_reason":"tool_calls"`},
+		},
+		{
+			name:     "error",
+			body:     `{"messages":[{"role":"user","content":"hi"}]}`,
+			response: "data: {\"error\":{\"code\":503,\"message\":\"The model is overloaded\"}}\n\n",
+			err:      true,
+		},
+	})
+}
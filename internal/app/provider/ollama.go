@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/tidwall/gjson"
+)
+
+// ollamaAdapter Ollama 原生 /api/chat 接口
+type ollamaAdapter struct{}
+
+func (ollamaAdapter) BuildRequest(ctx context.Context, u *Upstream, key string, body []byte) (*http.Request, error) {
+	payload := map[string]interface{}{
+		"model":    u.Model,
+		"messages": ollamaMessages(gjson.GetBytes(body, "messages")),
+		"options":  ollamaOptions(body),
+		"stream":   true,
+	}
+	if tools := gjson.GetBytes(body, "tools"); tools.IsArray() && gjson.GetBytes(body, "tool_choice").String() != "none" {
+		payload["tools"] = tools.Value()
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ollamaURL(u.Provider.APIBase), io.NopCloser(bytes.NewBuffer(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	return req, nil
+}
+
+// ollamaURL 拼接对话接口地址, 兼容填写了代码补全 /api/generate 地址的提供方
+func ollamaURL(base string) string {
+	base = strings.TrimSuffix(strings.TrimRight(base, "/"), "/api/generate")
+	return appendPath(base, "/api/chat")
+}
+
+// ollamaOptions 获取模型参数, 优先使用请求中的 options, 其次使用 OpenAI 格式的对应参数
+func ollamaOptions(body []byte) map[string]interface{} {
+	options := make(map[string]interface{})
+	if raw, ok := gjson.GetBytes(body, "options").Value().(map[string]interface{}); ok {
+		options = raw
+	}
+
+	setDefault := func(key string, value gjson.Result) {
+		if _, ok := options[key]; !ok && value.Exists() {
+			options[key] = value.Value()
+		}
+	}
+	setDefault("num_predict", gjson.GetBytes(body, "max_tokens"))
+	setDefault("temperature", gjson.GetBytes(body, "temperature"))
+	setDefault("top_p", gjson.GetBytes(body, "top_p"))
+	setDefault("seed", gjson.GetBytes(body, "seed"))
+	if _, ok := options["stop"]; !ok {
+		if stop := stopSequences(body); len(stop) > 0 {
+			options["stop"] = stop
+		}
+	}
+	return options
+}
+
+// ollamaMessages 转换对话消息, 图片转为 images 字段, 工具调用参数转为对象
+func ollamaMessages(messages gjson.Result) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0)
+	callNames := make(map[string]string)
+
+	for _, msg := range messages.Array() {
+		role := msg.Get("role").String()
+		if role == "developer" {
+			role = "system"
+		}
+		message := map[string]interface{}{
+			"role":    role,
+			"content": contentText(msg.Get("content")),
+		}
+
+		images := make([]string, 0)
+		for _, part := range msg.Get("content").Array() {
+			if part.Get("type").String() != "image_url" {
+				continue
+			}
+			if _, data, ok := parseDataURL(part.Get("image_url.url").String()); ok {
+				images = append(images, data)
+			}
+		}
+		if len(images) > 0 {
+			message["images"] = images
+		}
+
+		toolCalls := make([]map[string]interface{}, 0)
+		for _, call := range msg.Get("tool_calls").Array() {
+			name := call.Get("function.name").String()
+			callNames[call.Get("id").String()] = name
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"function": map[string]interface{}{
+					"name":      name,
+					"arguments": toolArguments(call.Get("function.arguments").String()),
+				},
+			})
+		}
+		if len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+		}
+
+		if role == "tool" {
+			message["tool_name"] = callNames[msg.Get("tool_call_id").String()]
+		}
+		result = append(result, message)
+	}
+	return result
+}
+
+func (ollamaAdapter) Stream(w StreamWriter, resp *http.Response, model string) error {
+	cw := newChunkWriter(w, "", model)
+	var toolCalls int
+
+	var writeErr, streamErr error
+	err := readNDJSON(resp.Body, func(line string) bool {
+		data := gjson.Parse(line)
+		if errMsg := data.Get("error"); errMsg.Exists() {
+			streamErr = &StreamError{Provider: TypeOllama, Message: errMsg.String()}
+			return false
+		}
+
+		message := data.Get("message")
+		if text := message.Get("content").String(); text != "" {
+			if writeErr = cw.content(text); writeErr != nil {
+				return false
+			}
+		}
+		// Ollama 的工具调用一次性完整返回, 且没有调用 id
+		for _, call := range message.Get("tool_calls").Array() {
+			arguments := call.Get("function.arguments").Raw
+			if arguments == "" {
+				arguments = "{}"
+			}
+			writeErr = cw.toolCall(toolCalls, "call_"+uuid.Must(uuid.NewV4()).String(), call.Get("function.name").String(), arguments)
+			if writeErr != nil {
+				return false
+			}
+			toolCalls++
+		}
+
+		if !data.Get("done").Bool() {
+			return true
+		}
+		finishReason := "stop"
+		if data.Get("done_reason").String() == "length" {
+			finishReason = "length"
+		} else if toolCalls > 0 {
+			finishReason = "tool_calls"
+		}
+		if writeErr = cw.delta(map[string]interface{}{}, finishReason); writeErr == nil {
+			writeErr = cw.usage(data.Get("prompt_eval_count").Int(), data.Get("eval_count").Int())
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	return cw.done()
+}
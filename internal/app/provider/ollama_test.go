@@ -0,0 +1,49 @@
+package provider
+
+import "testing"
+
+func TestOllamaAdapter(t *testing.T) {
+	runAdapterCases(t, TypeOllama, "/api/chat", "Authorization", []adapterCase{
+		{
+			name: "text",
+			body: `{"max_tokens":50,"temperature":0.2,"options":{"temperature":0.7},"messages":[
+				{"role":"developer","content":"be brief"},
+				{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}
+			]}`,
+			response: `{"message":{"role":"assistant","content":"hel"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":"lo"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":8,"eval_count":2}` + "\n",
+			want: map[string]string{
+				"model":               "upstream-model",
+				"stream":              "true",
+				"options.num_predict": "50",
+				"options.temperature": "0.7",
+				"messages.0.role":     "system",
+				"messages.1.images.0": "AAAA",
+			},
+			output: []string{`"content":"hel"`, `"content":"lo"`, `"finish_reason":"length"`, `"prompt_tokens":8`, `"completion_tokens":2`},
+		},
+		{
+			name: "tool call",
+			body: `{"messages":[
+				{"role":"user","content":"weather?"},
+				{"role":"assistant","tool_calls":[{"id":"call_1","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"}
+			],"tools":[{"type":"function","function":{"name":"weather","parameters":{"type":"object"}}}]}`,
+			response: `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}` + "\n",
+			want: map[string]string{
+				"messages.1.tool_calls.0.function.arguments.city": "Paris",
+				"messages.2.tool_name":                            "weather",
+				"tools.0.function.name":                           "weather",
+			},
+			output: []string{`"name":"weather"`, `"arguments":"{\"city\":\"Paris\"}"`, `"finish_reason":"tool_calls"`},
+		},
+		{
+			name:     "error",
+			body:     `{"messages":[{"role":"user","content":"hi"}]}`,
+			response: `{"error":"model 'upstream-model' not found"}` + "\n",
+			err:      true,
+		},
+	})
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"net/http"
+	"ripper/internal/app/httpclient"
+	"ripper/internal/app/keypool"
+	"ripper/internal/config"
+)
+
+// 上游接口协议类型
+const (
+	TypeOpenAI    = "openai"
+	TypeOllama    = "ollama"
+	TypeAnthropic = "anthropic"
+	TypeGemini    = "gemini"
+	TypeResponses = "responses"
+	TypeAzure     = "azure"
+)
+
+// Provider 上游服务提供方
+type Provider struct {
+	Name    string   `json:"-"`
+	Type    string   `json:"type"`     // 接口协议类型, 默认 openai
+	APIBase string   `json:"api_base"` // 完整的请求地址
+	APIKeys []string `json:"api_keys"` // 支持多个轮询 key, 可使用 key#权重 的格式设置权重
+
+	KeyStrategy string `json:"key_strategy"` // key 选择策略, 为空时使用 KEY_POOL_STRATEGY
+	APIVersion  string `json:"api_version"`  // azure 接口版本, 为空时使用 AZURE_API_VERSION
+
+	CAFile             string `json:"ca_file"`              // 额外信任的 CA 证书, 为空时使用 UPSTREAM_CA_FILE
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 跳过 TLS 证书校验, 仅用于自签名的内网上游
+}
+
+// Pool 获取提供方的 key 池
+func (p *Provider) Pool() *keypool.Pool {
+	return keypool.Get("provider:"+p.Name, p.APIKeys, p.KeyStrategy)
+}
+
+// Client 获取提供方共享的 http 客户端, 同一提供方的请求复用连接
+func (p *Provider) Client() (*http.Client, error) {
+	opts := httpclient.FromConfig(config.Current().Upstream)
+	if p.CAFile != "" {
+		opts.CAFile = p.CAFile
+	}
+	if p.InsecureSkipVerify {
+		opts.InsecureSkipVerify = true
+	}
+	return httpclient.Get("provider:"+p.Name, opts)
+}
+
+// URL 获取请求地址, azure 类型按部署名称拼接 operation 接口地址, 其余类型直接使用 api_base
+func (p *Provider) URL(deployment string, operation string) string {
+	if p.Type == TypeAzure {
+		return azureURL(p.APIBase, deployment, operation, p.APIVersion)
+	}
+	return p.APIBase
+}
+
+// SetAuth 设置鉴权请求头, azure 类型使用 api-key 请求头
+func (p *Provider) SetAuth(req *http.Request, key string) {
+	if p.Type == TypeAzure {
+		req.Header.Set("api-key", key)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+}
+
+// Profile 请求转换配置, 决定请求体在发往上游前如何改写
+type Profile struct {
+	UseTools  bool   `json:"use_tools"`  // 是否保留 tools 等工具调用参数
+	MaxTokens int    `json:"max_tokens"` // 最大响应 tokens
+	Locale    string `json:"locale"`     // 对话语言环境
+}
+
+// Target 路由指向的上游目标
+type Target struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"` // 上游真实模型名称, 为空时透传客户端的模型名称
+}
+
+// Route 模型路由, 将客户端选择的模型映射到上游
+type Route struct {
+	Target
+	Profile   string    `json:"profile"`   // 请求转换配置名称, 为空时使用 default
+	Fallbacks []*Target `json:"fallbacks"` // 主上游失败时按顺序尝试的备用上游
+}
+
+// Upstream 解析后的上游
+type Upstream struct {
+	Provider *Provider
+	Model    string
+}
+
+// Resolved 路由解析结果
+type Resolved struct {
+	Chain   Chain // 第一个为主上游, 其余为备用上游
+	Profile *Profile
+}
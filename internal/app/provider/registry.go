@@ -0,0 +1,338 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"ripper/internal/config"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// DefaultName 默认的提供方和请求转换配置名称
+	DefaultName = "default"
+	// lightweightName 轻量模型使用的提供方名称
+	lightweightName = "lightweight"
+	// codexName 代码补全使用的提供方名称
+	codexName = "codex"
+	// wildcardRoute 兜底路由
+	wildcardRoute = "*"
+)
+
+// Registry 上游提供方注册表
+type Registry struct {
+	Providers   map[string]*Provider
+	Profiles    map[string]*Profile
+	Routes      map[string]*Route // 对话模型路由
+	Completions *Route            // 代码补全路由
+
+	// 模型名称包含 LightweightModel 关键字时使用的轻量模型路由
+	LightweightModel string
+	Lightweight      *Route
+}
+
+// registryFile 提供方配置文件结构
+type registryFile struct {
+	Providers   map[string]*Provider       `json:"providers"`
+	Profiles    map[string]json.RawMessage `json:"profiles"`
+	Routes      map[string]*Route          `json:"routes"`
+	Completions *Route                     `json:"completions"`
+}
+
+// registry 全局注册表, 热加载时整体替换, 已开始处理的请求继续使用旧的注册表
+var registry atomic.Pointer[Registry]
+
+// Init 加载提供方配置文件并初始化全局注册表
+func Init(cfg *config.Config) error {
+	r, err := Load(cfg)
+	if err != nil {
+		return err
+	}
+	Set(r)
+	return nil
+}
+
+// Set 替换全局注册表
+func Set(r *Registry) {
+	// 预先创建 key 池, 便于在管理接口中查看
+	for _, p := range r.Providers {
+		p.Pool()
+	}
+	registry.Store(r)
+}
+
+// Current 获取当前的全局注册表
+func Current() *Registry {
+	return registry.Load()
+}
+
+// Secrets 所有提供方的 API key, 用于日志脱敏
+func (r *Registry) Secrets() []string {
+	var list []string
+	for _, p := range r.Providers {
+		for _, key := range p.APIKeys {
+			list = append(list, config.StripKeyWeight(key))
+		}
+	}
+	return list
+}
+
+// Load 从配置文件加载注册表, 文件不存在时仅使用服务配置中的默认上游
+func Load(cfg *config.Config) (*Registry, error) {
+	r := defaultRegistry(cfg)
+	path := cfg.Upstream.ProvidersFile
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read providers file %s: %v", path, err)
+	}
+
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse providers file %s: %v", path, err)
+	}
+
+	for name, p := range file.Providers {
+		r.Providers[name] = p
+	}
+
+	// 未声明的字段继承 default 配置
+	for name, raw := range file.Profiles {
+		profile := *r.Profiles[DefaultName]
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			return nil, fmt.Errorf("invalid profile %s: %v", name, err)
+		}
+		r.Profiles[name] = &profile
+	}
+
+	for model, route := range file.Routes {
+		r.Routes[model] = route
+	}
+
+	if file.Completions != nil {
+		r.Completions = file.Completions
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, fmt.Errorf("invalid providers file %s: %v", path, err)
+	}
+
+	slog.Info("loaded providers file", "providers", len(r.Providers), "routes", len(r.Routes), "path", path)
+	return r, nil
+}
+
+// defaultRegistry 根据服务配置构建默认注册表, 保持与单一上游配置的兼容
+func defaultRegistry(cfg *config.Config) *Registry {
+	chatType := TypeOpenAI
+	if t := cfg.Chat.ServiceType; t != "" && t != "default" {
+		chatType = t
+	}
+
+	codexType := TypeOpenAI
+	switch cfg.Codex.ServiceType {
+	case TypeOllama:
+		codexType = TypeOllama
+	case TypeAzure:
+		codexType = TypeAzure
+	}
+
+	r := &Registry{
+		Providers: map[string]*Provider{
+			DefaultName: {
+				Type:    chatType,
+				APIBase: cfg.Chat.APIBase,
+				APIKeys: cfg.Chat.APIKeys,
+			},
+			codexName: {
+				Type:    codexType,
+				APIBase: cfg.Codex.APIBase,
+				APIKeys: cfg.Codex.APIKeys,
+			},
+		},
+		Profiles: map[string]*Profile{
+			DefaultName: {
+				UseTools:  cfg.Chat.UseTools,
+				MaxTokens: cfg.Chat.MaxTokens,
+				Locale:    cfg.Chat.Locale,
+			},
+		},
+		Routes: map[string]*Route{
+			wildcardRoute: {
+				Target: Target{
+					Provider: DefaultName,
+					Model:    cfg.Chat.ModelName,
+				},
+			},
+		},
+		Completions: &Route{
+			Target: Target{
+				Provider: codexName,
+				Model:    cfg.Codex.ModelName,
+			},
+		},
+	}
+
+	// 轻量模型直接走代码补全服务, 节约成本
+	if lightweightModel := cfg.Chat.LightweightModel; lightweightModel != "" {
+		lightweightType := TypeOpenAI
+		if codexType == TypeAzure {
+			lightweightType = TypeAzure
+		}
+		r.Providers[lightweightName] = &Provider{
+			Type:    lightweightType,
+			APIBase: strings.Replace(cfg.Codex.APIBase, "/v1/completions", "/v1/chat/completions", 1),
+			APIKeys: cfg.Codex.APIKeys,
+		}
+		r.LightweightModel = lightweightModel
+		r.Lightweight = &Route{
+			Target: Target{
+				Provider: lightweightName,
+				Model:    cfg.Codex.ModelName,
+			},
+		}
+	}
+
+	for name, p := range r.Providers {
+		p.Name = name
+	}
+	return r
+}
+
+// validate 校验注册表配置是否完整
+func (r *Registry) validate() error {
+	for name, p := range r.Providers {
+		if p == nil {
+			return fmt.Errorf("provider %s is empty", name)
+		}
+		p.Name = name
+		if p.Type == "" {
+			p.Type = TypeOpenAI
+		}
+		if _, ok := chatAdapters[p.Type]; !ok {
+			return fmt.Errorf("provider %s has unsupported type %s", name, p.Type)
+		}
+	}
+
+	for model, route := range r.Routes {
+		if err := r.validateRoute(route, chatTypes()...); err != nil {
+			return fmt.Errorf("route %s: %v", model, err)
+		}
+	}
+
+	if r.Lightweight != nil {
+		if err := r.validateRoute(r.Lightweight, chatTypes()...); err != nil {
+			return fmt.Errorf("lightweight route: %v", err)
+		}
+	}
+
+	if err := r.validateRoute(r.Completions, TypeOpenAI, TypeOllama, TypeAzure); err != nil {
+		return fmt.Errorf("completions route: %v", err)
+	}
+	return nil
+}
+
+// validateRoute 校验路由及其备用上游引用的提供方
+func (r *Registry) validateRoute(route *Route, types ...string) error {
+	if route == nil {
+		return fmt.Errorf("route is empty")
+	}
+	if route.Profile != "" {
+		if _, ok := r.Profiles[route.Profile]; !ok {
+			return fmt.Errorf("unknown profile %s", route.Profile)
+		}
+	}
+
+	targets := append([]*Target{&route.Target}, route.Fallbacks...)
+	for _, target := range targets {
+		if target == nil {
+			return fmt.Errorf("fallback is empty")
+		}
+		p, ok := r.Providers[target.Provider]
+		if !ok {
+			return fmt.Errorf("unknown provider %s", target.Provider)
+		}
+		if p.APIBase == "" {
+			return fmt.Errorf("provider %s is missing api_base", target.Provider)
+		}
+		supported := false
+		for _, t := range types {
+			supported = supported || p.Type == t
+		}
+		if !supported {
+			return fmt.Errorf("provider %s with type %s is not supported here", target.Provider, p.Type)
+		}
+	}
+	return nil
+}
+
+// Resolve 根据客户端选择的模型解析对话路由
+// 匹配顺序: 精确匹配 > 包含 LIGHTWEIGHT_MODEL 关键字的轻量模型 > 最长前缀匹配 > 兜底路由
+func (r *Registry) Resolve(model string) (*Resolved, error) {
+	route, ok := r.Routes[model]
+	if !ok && r.Lightweight != nil && strings.Contains(model, r.LightweightModel) {
+		route, ok = r.Lightweight, true
+	}
+	if !ok {
+		matched := ""
+		for key, candidate := range r.Routes {
+			if key == wildcardRoute || !strings.HasPrefix(model, key) {
+				continue
+			}
+			if len(key) > len(matched) {
+				matched, route = key, candidate
+			}
+		}
+	}
+	if route == nil {
+		route = r.Routes[wildcardRoute]
+	}
+	if route == nil {
+		return nil, fmt.Errorf("no route for model %s", model)
+	}
+	return r.resolveRoute(route, model)
+}
+
+// ResolveCompletions 解析代码补全路由
+func (r *Registry) ResolveCompletions(model string) (*Resolved, error) {
+	if r.Completions == nil {
+		return nil, fmt.Errorf("no route for completions")
+	}
+	return r.resolveRoute(r.Completions, model)
+}
+
+// resolveRoute 将路由展开为按顺序尝试的上游链
+func (r *Registry) resolveRoute(route *Route, model string) (*Resolved, error) {
+	targets := append([]*Target{&route.Target}, route.Fallbacks...)
+	chain := make(Chain, 0, len(targets))
+	for _, target := range targets {
+		p, ok := r.Providers[target.Provider]
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %s for model %s", target.Provider, model)
+		}
+
+		upstreamModel := target.Model
+		if upstreamModel == "" {
+			upstreamModel = model
+		}
+		chain = append(chain, &Upstream{Provider: p, Model: upstreamModel})
+	}
+
+	profileName := route.Profile
+	if profileName == "" {
+		profileName = DefaultName
+	}
+
+	return &Resolved{
+		Chain:   chain,
+		Profile: r.Profiles[profileName],
+	}, nil
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"ripper/internal/config"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	cfg := config.Default()
+	cfg.Chat.APIBase = "https://chat.example.com/v1/chat/completions"
+	cfg.Chat.ModelName = "chat-model"
+	cfg.Chat.LightweightModel = "mini"
+	cfg.Codex.APIBase = "https://codex.example.com/v1/completions"
+	cfg.Codex.ModelName = "codex-model"
+
+	path := filepath.Join(t.TempDir(), "providers.json")
+	data := `{
+		"providers": {"deepseek": {"api_base": "https://api.deepseek.com/v1/chat/completions"}},
+		"routes": {
+			"deepseek": {"provider": "deepseek", "model": "deepseek-chat"},
+			"gpt-4o-mini-exact": {"provider": "deepseek", "model": "exact"}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Upstream.ProvidersFile = path
+
+	r, err := Load(cfg)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		model    string
+		provider string
+		upstream string
+	}{
+		{"gpt-4o-mini", lightweightName, "codex-model"},
+		{"o4-mini-2025", lightweightName, "codex-model"},
+		{"gpt-4o-mini-exact", "deepseek", "exact"},
+		{"deepseek-reasoner", "deepseek", "deepseek-chat"},
+		{"gpt-4o", DefaultName, "chat-model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			resolved, err := r.Resolve(tt.model)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			u := resolved.Chain[0]
+			if u.Provider.Name != tt.provider || u.Model != tt.upstream {
+				t.Fatalf("Resolve(%s) = %s/%s, want %s/%s", tt.model, u.Provider.Name, u.Model, tt.provider, tt.upstream)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// responsesAdapter OpenAI Responses API
+type responsesAdapter struct{}
+
+func (responsesAdapter) BuildRequest(ctx context.Context, u *Upstream, key string, body []byte) (*http.Request, error) {
+	instructions, input := responsesInput(gjson.GetBytes(body, "messages"))
+	payload := map[string]interface{}{
+		"model":             u.Model,
+		"input":             input,
+		"max_output_tokens": maxTokens(body),
+		"stream":            true,
+		"store":             false,
+	}
+	if instructions != "" {
+		payload["instructions"] = instructions
+	}
+	if temperature := gjson.GetBytes(body, "temperature"); temperature.Exists() {
+		payload["temperature"] = temperature.Float()
+	}
+	if topP := gjson.GetBytes(body, "top_p"); topP.Exists() {
+		payload["top_p"] = topP.Float()
+	}
+	if tools := responsesTools(gjson.GetBytes(body, "tools")); len(tools) > 0 {
+		payload["tools"] = tools
+		if choice := responsesToolChoice(gjson.GetBytes(body, "tool_choice")); choice != nil {
+			payload["tool_choice"] = choice
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responsesURL(u.Provider.APIBase), io.NopCloser(bytes.NewBuffer(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	return req, nil
+}
+
+// responsesURL 拼接 Responses 接口地址, api_base 可只填写服务域名或 /v1 地址
+func responsesURL(base string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, "/v1") {
+		return base + "/responses"
+	}
+	return appendPath(base, "/v1/responses")
+}
+
+// responsesInput 将对话消息转换为 input items, system 消息合并为 instructions
+func responsesInput(messages gjson.Result) (string, []map[string]interface{}) {
+	var instructions string
+	input := make([]map[string]interface{}, 0)
+
+	for _, msg := range messages.Array() {
+		switch role := msg.Get("role").String(); role {
+		case "system", "developer":
+			if instructions != "" {
+				instructions += "\n\n"
+			}
+			instructions += contentText(msg.Get("content"))
+		case "assistant":
+			if text := contentText(msg.Get("content")); text != "" {
+				input = append(input, map[string]interface{}{
+					"type":    "message",
+					"role":    "assistant",
+					"content": []map[string]interface{}{{"type": "output_text", "text": text}},
+				})
+			}
+			for _, call := range msg.Get("tool_calls").Array() {
+				input = append(input, map[string]interface{}{
+					"type":      "function_call",
+					"call_id":   call.Get("id").String(),
+					"name":      call.Get("function.name").String(),
+					"arguments": call.Get("function.arguments").String(),
+				})
+			}
+		case "tool":
+			input = append(input, map[string]interface{}{
+				"type":    "function_call_output",
+				"call_id": msg.Get("tool_call_id").String(),
+				"output":  contentText(msg.Get("content")),
+			})
+		default:
+			if content := responsesUserContent(msg.Get("content")); len(content) > 0 {
+				input = append(input, map[string]interface{}{
+					"type":    "message",
+					"role":    "user",
+					"content": content,
+				})
+			}
+		}
+	}
+	return instructions, input
+}
+
+// responsesUserContent 转换用户消息内容, 支持文本和图片
+func responsesUserContent(content gjson.Result) []map[string]interface{} {
+	if !content.IsArray() {
+		if content.String() == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"type": "input_text", "text": content.String()}}
+	}
+
+	parts := make([]map[string]interface{}, 0)
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "text":
+			parts = append(parts, map[string]interface{}{"type": "input_text", "text": part.Get("text").String()})
+		case "image_url":
+			parts = append(parts, map[string]interface{}{"type": "input_image", "image_url": part.Get("image_url.url").String()})
+		}
+	}
+	return parts
+}
+
+// responsesTools 转换工具定义, Responses API 的函数字段与 type 平级
+func responsesTools(tools gjson.Result) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0)
+	for _, tool := range tools.Array() {
+		function := tool.Get("function")
+		if !function.Exists() {
+			continue
+		}
+		converted := map[string]interface{}{
+			"type":        "function",
+			"name":        function.Get("name").String(),
+			"description": function.Get("description").String(),
+		}
+		if parameters := function.Get("parameters"); parameters.Exists() {
+			converted["parameters"] = parameters.Value()
+		}
+		result = append(result, converted)
+	}
+	return result
+}
+
+// responsesToolChoice 转换工具选择方式
+func responsesToolChoice(choice gjson.Result) interface{} {
+	if choice.IsObject() {
+		return map[string]interface{}{"type": "function", "name": choice.Get("function.name").String()}
+	}
+	if choice.Exists() {
+		return choice.String()
+	}
+	return nil
+}
+
+func (responsesAdapter) Stream(w StreamWriter, resp *http.Response, model string) error {
+	var cw *chunkWriter
+	// output 下标与工具调用下标的对应关系
+	toolIndex := make(map[int64]int)
+
+	var writeErr, streamErr error
+	err := readSSE(resp.Body, func(ev sseEvent) bool {
+		data := gjson.Parse(ev.Data)
+		if cw == nil {
+			cw = newChunkWriter(w, data.Get("response.id").String(), model)
+		}
+
+		switch data.Get("type").String() {
+		case "response.created":
+			writeErr = cw.delta(map[string]interface{}{"role": "assistant", "content": ""}, "")
+		case "response.output_item.added":
+			item := data.Get("item")
+			if item.Get("type").String() == "function_call" {
+				index := len(toolIndex)
+				toolIndex[data.Get("output_index").Int()] = index
+				writeErr = cw.toolCall(index, item.Get("call_id").String(), item.Get("name").String(), item.Get("arguments").String())
+			}
+		case "response.output_text.delta":
+			writeErr = cw.content(data.Get("delta").String())
+		case "response.function_call_arguments.delta":
+			writeErr = cw.toolCall(toolIndex[data.Get("output_index").Int()], "", "", data.Get("delta").String())
+		case "response.completed", "response.incomplete":
+			finishReason := "stop"
+			switch data.Get("response.incomplete_details.reason").String() {
+			case "max_output_tokens":
+				finishReason = "length"
+			case "content_filter":
+				finishReason = "content_filter"
+			default:
+				if len(toolIndex) > 0 {
+					finishReason = "tool_calls"
+				}
+			}
+			usage := data.Get("response.usage")
+			if writeErr = cw.delta(map[string]interface{}{}, finishReason); writeErr == nil {
+				writeErr = cw.usage(usage.Get("input_tokens").Int(), usage.Get("output_tokens").Int())
+			}
+			return false
+		case "response.failed", "error":
+			message := data.Get("response.error.message").String()
+			if message == "" {
+				message = data.Get("message").String()
+			}
+			streamErr = &StreamError{Provider: TypeResponses, Message: message}
+			return false
+		}
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	if cw == nil {
+		cw = newChunkWriter(w, "", model)
+	}
+	return cw.done()
+}
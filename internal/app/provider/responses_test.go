@@ -0,0 +1,55 @@
+package provider
+
+import "testing"
+
+func TestResponsesAdapter(t *testing.T) {
+	runAdapterCases(t, TypeResponses, "/v1/responses", "Authorization", []adapterCase{
+		{
+			name: "text",
+			body: `{"max_tokens":100,"messages":[
+				{"role":"system","content":"be brief"},
+				{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}
+			]}`,
+			response: "data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_1\"}}\n\n" +
+				"data: {\"type\":\"response.output_text.delta\",\"delta\":\"hello\"}\n\n" +
+				"data: {\"type\":\"response.incomplete\",\"response\":{\"incomplete_details\":{\"reason\":\"max_output_tokens\"},\"usage\":{\"input_tokens\":6,\"output_tokens\":4}}}\n\n",
+			want: map[string]string{
+				"model":                  "upstream-model",
+				"instructions":           "be brief",
+				"store":                  "false",
+				"max_output_tokens":      "100",
+				"input.0.content.0.type": "input_text",
+				"input.0.content.1.type": "input_image",
+			},
+			output: []string{`"id":"resp_1"`, `"content":"hello"`, `"finish_reason":"length"`, `"prompt_tokens":6`, `"completion_tokens":4`},
+		},
+		{
+			name: "function call",
+			body: `{"messages":[
+				{"role":"user","content":"weather?"},
+				{"role":"assistant","tool_calls":[{"id":"call_1","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"sunny"}
+			],"tools":[{"type":"function","function":{"name":"weather","parameters":{"type":"object"}}}],"tool_choice":"auto"}`,
+			response: "data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_2\"}}\n\n" +
+				"data: {\"type\":\"response.output_item.added\",\"output_index\":0,\"item\":{\"type\":\"function_call\",\"call_id\":\"call_2\",\"name\":\"weather\",\"arguments\":\"\"}}\n\n" +
+				"data: {\"type\":\"response.function_call_arguments.delta\",\"output_index\":0,\"delta\":\"{}\"}\n\n" +
+				"data: {\"type\":\"response.completed\",\"response\":{\"usage\":{\"input_tokens\":1,\"output_tokens\":1}}}\n\n",
+			want: map[string]string{
+				"input.1.type":    "function_call",
+				"input.1.call_id": "call_1",
+				"input.2.type":    "function_call_output",
+				"input.2.output":  "sunny",
+				"tools.0.name":    "weather",
+				"tool_choice":     "auto",
+			},
+			output: []string{`"id":"call_2"`, `"name":"weather"`, `"finish_reason":"tool_calls"`},
+		},
+		{
+			name: "failed",
+			body: `{"messages":[{"role":"user","content":"hi"}]}`,
+			response: "data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_3\"}}\n\n" +
+				"data: {\"type\":\"response.failed\",\"response\":{\"error\":{\"code\":\"server_error\",\"message\":\"boom\"}}}\n\n",
+			err: true,
+		},
+	})
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// maxLineSize 单行流式数据的最大长度
+const maxLineSize = 4 * 1024 * 1024
+
+// StreamError 上游在流式响应中返回的错误, 出现时不输出流结束标记, 客户端据此判断对话未正常结束
+type StreamError struct {
+	Provider string
+	Message  string
+}
+
+func (e *StreamError) Error() string {
+	return e.Provider + " stream error: " + e.Message
+}
+
+// chunkWriter 输出 OpenAI chat.completion.chunk 格式的 SSE
+type chunkWriter struct {
+	w       StreamWriter
+	id      string
+	model   string
+	created int64
+}
+
+// newChunkWriter 创建 chunk 输出, id 为空时自动生成
+func newChunkWriter(w StreamWriter, id string, model string) *chunkWriter {
+	if id == "" {
+		id = "chatcmpl-" + uuid.Must(uuid.NewV4()).String()
+	}
+	return &chunkWriter{w: w, id: id, model: model, created: time.Now().Unix()}
+}
+
+// delta 输出一个增量 chunk, finishReason 为空时输出 null
+func (cw *chunkWriter) delta(delta map[string]interface{}, finishReason string) error {
+	var reason interface{}
+	if finishReason != "" {
+		reason = finishReason
+	}
+	return cw.write(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"index": 0, "delta": delta, "finish_reason": reason},
+		},
+	})
+}
+
+// content 输出文本增量
+func (cw *chunkWriter) content(text string) error {
+	return cw.delta(map[string]interface{}{"role": "assistant", "content": text}, "")
+}
+
+// toolCall 输出工具调用增量, id 和 name 仅在首个增量中携带
+func (cw *chunkWriter) toolCall(index int, id string, name string, arguments string) error {
+	function := map[string]interface{}{"arguments": arguments}
+	call := map[string]interface{}{"index": index, "function": function}
+	if id != "" {
+		call["id"] = id
+		call["type"] = "function"
+	}
+	if name != "" {
+		function["name"] = name
+	}
+	return cw.delta(map[string]interface{}{
+		"role":       "assistant",
+		"tool_calls": []map[string]interface{}{call},
+	}, "")
+}
+
+// usage 输出 token 用量
+func (cw *chunkWriter) usage(promptTokens int64, completionTokens int64) error {
+	return cw.write(map[string]interface{}{
+		"choices": []map[string]interface{}{},
+		"usage": map[string]interface{}{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		},
+	})
+}
+
+// done 输出流结束标记
+func (cw *chunkWriter) done() error {
+	if _, err := io.WriteString(cw.w, "data: [DONE]\n\n"); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return nil
+}
+
+// write 补全 chunk 公共字段并输出
+func (cw *chunkWriter) write(chunk map[string]interface{}) error {
+	chunk["id"] = cw.id
+	chunk["object"] = "chat.completion.chunk"
+	chunk["created"] = cw.created
+	chunk["model"] = cw.model
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(cw.w, "data: "+string(data)+"\n\n"); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return nil
+}
+
+// sseEvent 一个服务端推送事件
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE 逐个读取 SSE 事件, handle 返回 false 时停止读取
+func readSSE(r io.Reader, handle func(ev sseEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	var ev sseEvent
+	var data []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				ev.Data = strings.Join(data, "\n")
+				if !handle(ev) {
+					return nil
+				}
+			}
+			ev, data = sseEvent{}, nil
+		case strings.HasPrefix(line, "event:"):
+			ev.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(data) > 0 {
+		ev.Data = strings.Join(data, "\n")
+		handle(ev)
+	}
+	return scanner.Err()
+}
+
+// readNDJSON 逐行读取 NDJSON, 跳过空行, handle 返回 false 时停止读取
+func readNDJSON(r io.Reader, handle func(line string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !handle(line) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
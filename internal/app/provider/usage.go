@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// UsageObserver 由响应写入器实现, 接收未转发给客户端的 usage chunk 用于统计用量
+type UsageObserver interface {
+	ObserveUsage(chunk []byte)
+}
+
+// stripUsageKey 请求上下文中标记需要丢弃 usage chunk 的键, 由 IncludeUsage 的调用方设置
+type stripUsageKey struct{}
+
+// IncludeUsage 为 OpenAI 兼容的流式请求设置 stream_options.include_usage, 使上游在最后返回 usage chunk
+// 返回客户端是否自己请求了 usage, 未请求时转发响应需要丢弃该 chunk
+func IncludeUsage(body []byte) ([]byte, bool) {
+	if !gjson.GetBytes(body, "stream").Bool() {
+		return body, true
+	}
+	if gjson.GetBytes(body, "stream_options.include_usage").Bool() {
+		return body, true
+	}
+	body, _ = sjson.SetBytes(body, "stream_options.include_usage", true)
+	return body, false
+}
+
+// withStripUsage 标记请求的响应需要丢弃 usage chunk
+func withStripUsage(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), stripUsageKey{}, true))
+}
+
+// stripUsage 判断响应是否需要丢弃 usage chunk
+func stripUsage(resp *http.Response) bool {
+	if resp.Request == nil {
+		return false
+	}
+	strip, _ := resp.Request.Context().Value(stripUsageKey{}).(bool)
+	return strip
+}
+
+// isUsageChunk 判断是否为只包含 usage 的 chunk
+func isUsageChunk(data gjson.Result) bool {
+	return len(data.Get("choices").Array()) == 0 && data.Get("usage").IsObject()
+}
+
+// observeUsage 将未转发的 usage chunk 交给写入器统计
+func observeUsage(w StreamWriter, data string) {
+	if o, ok := w.(UsageObserver); ok {
+		o.ObserveUsage([]byte(data))
+	}
+}
+
+// CopyStream 转发 OpenAI 格式的 SSE 响应, strip 为 true 时丢弃只包含 usage 的 chunk, 改为交给 UsageObserver 统计
+func CopyStream(w StreamWriter, r io.Reader, strip bool) error {
+	if !strip {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	var writeErr error
+	err := readSSE(r, func(ev sseEvent) bool {
+		if ev.Data != "[DONE]" && isUsageChunk(gjson.Parse(ev.Data)) {
+			observeUsage(w, ev.Data)
+			return true
+		}
+		if _, writeErr = io.WriteString(w, "data: "+ev.Data+"\n\n"); writeErr != nil {
+			return false
+		}
+		w.Flush()
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// recordWriter 记录写入内容和被丢弃的 usage chunk
+type recordWriter struct {
+	bytes.Buffer
+	observed []string
+}
+
+func (w *recordWriter) Flush() {}
+
+func (w *recordWriter) ObserveUsage(chunk []byte) {
+	w.observed = append(w.observed, string(chunk))
+}
+
+func TestIncludeUsage(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		include   bool
+		requested bool
+	}{
+		{"stream", `{"stream":true}`, true, false},
+		{"requested", `{"stream":true,"stream_options":{"include_usage":true}}`, true, true},
+		{"not stream", `{"stream":false}`, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, requested := IncludeUsage([]byte(tt.body))
+			if got := gjson.GetBytes(body, "stream_options.include_usage").Bool(); got != tt.include || requested != tt.requested {
+				t.Fatalf("IncludeUsage(%s) = %s, %v", tt.body, body, requested)
+			}
+		})
+	}
+}
+
+func TestCopyStream(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":2}}\n\n" +
+		"data: [DONE]\n\n"
+
+	tests := []struct {
+		name     string
+		strip    bool
+		observed int
+	}{
+		{"forward", false, 0},
+		{"strip", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recordWriter{}
+			if err := CopyStream(w, strings.NewReader(stream), tt.strip); err != nil {
+				t.Fatalf("CopyStream: %v", err)
+			}
+			if got := strings.Contains(w.String(), "usage"); got == tt.strip {
+				t.Fatalf("CopyStream output = %q", w.String())
+			}
+			if !strings.HasSuffix(w.String(), "data: [DONE]\n\n") || len(w.observed) != tt.observed {
+				t.Fatalf("CopyStream output = %q, observed = %v", w.String(), w.observed)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"ripper/internal/cache"
+	"time"
+)
+
+// 请求类别, 每个类别单独计数
+const (
+	Completions = "completions"
+	Chat        = "chat"
+	Embeddings  = "embeddings"
+)
+
+// Check 检查是否超出限制并记录本次请求, 超出时返回需要等待的时间
+// 每分钟请求数按自然分钟计数, 每天 token 数按服务器时区的自然日计数, 0 表示不限制
+func Check(ctx context.Context, category string, key string, rpm int, tpd int) (time.Duration, error) {
+	now := time.Now()
+	if tpd > 0 {
+		used, err := cache.Counter(ctx, tpdKey(category, key, now))
+		if err != nil {
+			return 0, err
+		}
+		if used >= int64(tpd) {
+			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			return tomorrow.Sub(now), nil
+		}
+	}
+
+	if rpm > 0 {
+		window := now.Truncate(time.Minute)
+		count, err := cache.IncrBy(ctx, rpmKey(category, key, window), 1, 2*60)
+		if err != nil {
+			return 0, err
+		}
+		if count > int64(rpm) {
+			return window.Add(time.Minute).Sub(now), nil
+		}
+	}
+	return 0, nil
+}
+
+// AddTokens 记录当天使用的 token 数
+func AddTokens(ctx context.Context, category string, key string, tokens int) error {
+	if tokens <= 0 {
+		return nil
+	}
+	_, err := cache.IncrBy(ctx, tpdKey(category, key, time.Now()), int64(tokens), 2*24*3600)
+	return err
+}
+
+// rpmKey 每分钟请求数的缓存键
+func rpmKey(category string, key string, window time.Time) string {
+	return "ratelimit_rpm_" + category + "_" + key + "_" + window.Format("200601021504")
+}
+
+// tpdKey 每天 token 数的缓存键
+func tpdKey(category string, key string, day time.Time) string {
+	return "ratelimit_tpd_" + category + "_" + key + "_" + day.Format("20060102")
+}
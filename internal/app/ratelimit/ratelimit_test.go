@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"ripper/internal/cache"
+	"ripper/internal/config"
+	"testing"
+)
+
+func TestTPDCounterSurvivesCachePressure(t *testing.T) {
+	if err := cache.Init(config.CacheConfig{Type: "memory", MaxEntries: 10}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	ctx := context.Background()
+
+	if err := AddTokens(ctx, Chat, "user:a", 100); err != nil {
+		t.Fatalf("AddTokens: %v", err)
+	}
+	wait, err := Check(ctx, Chat, "user:a", 0, 100)
+	if err != nil || wait <= 0 {
+		t.Fatalf("Check before pressure = %v, %v, want positive wait", wait, err)
+	}
+
+	// 大量写入其他键, 超过 CACHE_MAX_ENTRIES 触发淘汰
+	for i := 0; i < 1000; i++ {
+		if err := cache.Set(ctx, fmt.Sprintf("pressure_%d", i), i, 60); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	wait, err = Check(ctx, Chat, "user:a", 0, 100)
+	if err != nil || wait <= 0 {
+		t.Fatalf("Check after pressure = %v, %v, want positive wait", wait, err)
+	}
+	if stats, ok := cache.Stats(); !ok || stats.Evictions == 0 {
+		t.Fatalf("Stats = %+v, want evictions", stats)
+	}
+}
+
+func TestBoltCountersStayInMemory(t *testing.T) {
+	err := cache.Init(config.CacheConfig{Type: "bolt", Path: filepath.Join(t.TempDir(), "cache.db")})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if wait, err := Check(ctx, Chat, "user:b", 1, 0); err != nil || (wait > 0) != (i > 0) {
+			t.Fatalf("Check #%d = %v, %v", i+1, wait, err)
+		}
+	}
+	keys, err := cache.Keys(ctx, "ratelimit_")
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("bolt keys = %v, %v, want no rate limit counters on disk", keys, err)
+	}
+}
@@ -0,0 +1,53 @@
+package reload
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	// envFile .env 文件路径, 为空表示未启用
+	envFile string
+	// processEnv 进程启动时已存在的环境变量, 不会被 .env 文件覆盖
+	processEnv map[string]bool
+	// fileEnv 上次从 .env 文件加载的环境变量
+	fileEnv map[string]string
+)
+
+// LoadEnvFile 加载 .env 文件并记录路径, 热加载时会重新读取
+// 与 godotenv.Load 一致, 已存在的系统环境变量优先
+func LoadEnvFile(path string) error {
+	envFile = path
+	processEnv = make(map[string]bool)
+	for _, kv := range os.Environ() {
+		if key, _, ok := strings.Cut(kv, "="); ok {
+			processEnv[key] = true
+		}
+	}
+	return applyEnvFile()
+}
+
+// applyEnvFile 将 .env 文件内容同步到环境变量, 文件中删除的变量会被清除
+// 文件不存在时视为空文件, 但仍返回错误以便启动时提示
+func applyEnvFile() error {
+	values, err := godotenv.Read(envFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for key := range fileEnv {
+		if _, ok := values[key]; !ok && !processEnv[key] {
+			os.Unsetenv(key)
+		}
+	}
+	for key, value := range values {
+		if !processEnv[key] {
+			os.Setenv(key, value)
+		}
+	}
+	fileEnv = values
+	return err
+}
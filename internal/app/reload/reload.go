@@ -0,0 +1,60 @@
+package reload
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"ripper/internal/app/fim"
+	"ripper/internal/app/models"
+	"ripper/internal/app/provider"
+	"ripper/internal/config"
+	"ripper/pkg/jwt"
+	"ripper/pkg/logs"
+)
+
+// Reload 重新加载 .env、配置文件、模型列表、多模型路由和 FIM 模板
+// 全部加载并校验成功后才会替换, 任意一项失败时继续使用旧的配置
+func Reload(configFile string) error {
+	if envFile != "" {
+		if err := applyEnvFile(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to reload %s: %v", envFile, err)
+		}
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	registry, err := provider.Load(cfg)
+	if err != nil {
+		return err
+	}
+	templates, err := fim.Load(cfg.Codex.FIMTemplatesFile)
+	if err != nil {
+		return err
+	}
+	modelList, err := models.Load(cfg.Chat.ModelsFile)
+	if err != nil {
+		return err
+	}
+
+	old := config.Current()
+	if old.Server.Host != cfg.Server.Host || old.Server.Port != cfg.Server.Port || old.Server.HTTPSPort != cfg.Server.HTTPSPort {
+		slog.Warn("HOST, PORT and HTTPS_PORT changes take effect after restart")
+	}
+	if old.Auth.TokenSalt != cfg.Auth.TokenSalt {
+		slog.Warn("TOKEN_SALT changed, issued tokens are no longer valid")
+	}
+
+	config.Set(cfg)
+	jwt.SetSigningKey(cfg.Auth.TokenSalt)
+	provider.Set(registry)
+	fim.Set(templates)
+	models.Set(modelList)
+	logs.SetSecrets(append(cfg.Secrets(), registry.Secrets()...))
+	if err := logs.SetLevel(cfg.Log.Level); err != nil {
+		slog.Warn("failed to apply log level", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package reload
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"ripper/internal/config"
+	"syscall"
+	"time"
+)
+
+// fileState 文件的修改时间和大小, 用于判断文件是否变化
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// Watch 监听配置相关文件的变化及 SIGHUP 信号, 触发时重新加载配置
+// 文件变化通过定时检查修改时间判断, 间隔为 CONFIG_RELOAD_INTERVAL 秒
+func Watch(ctx context.Context, configFile string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	states := snapshot(watchedFiles(configFile))
+	for {
+		if !wait(ctx, hup, configFile, states) {
+			return
+		}
+
+		if err := Reload(configFile); err != nil {
+			slog.Error("failed to reload configuration, keep using the previous one", "error", err)
+		} else {
+			slog.Info("configuration reloaded")
+		}
+		// 重新加载后文件列表可能变化, 以最新的文件状态为准
+		states = snapshot(watchedFiles(configFile))
+	}
+}
+
+// wait 等待 SIGHUP 信号或文件变化, ctx 结束时返回 false
+func wait(ctx context.Context, hup <-chan os.Signal, configFile string, states map[string]fileState) bool {
+	for {
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if interval := config.Current().ReloadInterval; interval > 0 {
+			timer = time.NewTimer(time.Duration(interval) * time.Second)
+			tick = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return false
+		case <-hup:
+			if timer != nil {
+				timer.Stop()
+			}
+			slog.Info("SIGHUP received, reloading configuration")
+			return true
+		case <-tick:
+			if changed(states, snapshot(watchedFiles(configFile))) {
+				slog.Info("configuration file change detected, reloading configuration")
+				return true
+			}
+		}
+	}
+}
+
+// watchedFiles 需要检查变化的文件列表
+func watchedFiles(configFile string) []string {
+	cfg := config.Current()
+	files := []string{configFile, cfg.Chat.ModelsFile, cfg.Upstream.ProvidersFile, cfg.Codex.FIMTemplatesFile}
+	if envFile != "" {
+		files = append(files, envFile)
+	}
+	return files
+}
+
+// snapshot 获取文件的当前状态
+func snapshot(files []string) map[string]fileState {
+	states := make(map[string]fileState, len(files))
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			states[file] = fileState{}
+			continue
+		}
+		states[file] = fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+	}
+	return states
+}
+
+// changed 判断文件状态是否有变化
+func changed(old, current map[string]fileState) bool {
+	if len(old) != len(current) {
+		return true
+	}
+	for file, state := range current {
+		if old[file] != state {
+			return true
+		}
+	}
+	return false
+}
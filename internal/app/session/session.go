@@ -0,0 +1,73 @@
+package session
+
+import (
+	"errors"
+	"ripper/internal/app/users"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// ExpiresIn 插件登录 JWT 的有效期, days 为 0 时长期有效
+// 插件不会刷新令牌, 长期有效时与早期版本一致, 有效期为签发时时间戳的秒数
+func ExpiresIn(days int) time.Duration {
+	if days <= 0 {
+		return time.Duration(time.Now().Unix()) * time.Second
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Session 登录会话, 对应一次插件登录签发的 JWT, ID 即 JWT 的 jti
+type Session = users.Session
+
+// NewID 生成会话 ID
+func NewID() string {
+	id, _ := uuid.NewV4()
+	return strings.ReplaceAll(id.String(), "-", "")
+}
+
+// Create 记录会话, 会话在 JWT 过期后自动清理
+func Create(s *Session) error {
+	if !s.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	return users.Sessions().CreateSession(s)
+}
+
+// List 获取未过期的会话, userID 和 clientID 为空时不过滤, 按创建时间排序
+func List(userID string, clientID string) ([]*Session, error) {
+	return users.Sessions().ListSessions(userID, clientID)
+}
+
+// Revoke 撤销会话, 该会话的 JWT 及由其换取的 Copilot token 立即失效
+// 撤销记录保留到 JWT 过期, 会话记录不存在时无法得知过期时间, 撤销记录永久保留
+func Revoke(id string) error {
+	var expiresAt time.Time
+	s, err := users.Sessions().GetSession(id)
+	if err == nil {
+		expiresAt = s.ExpiresAt
+	} else if !errors.Is(err, users.ErrNotFound) {
+		return err
+	}
+	return users.Sessions().RevokeSession(id, expiresAt)
+}
+
+// RevokeAll 撤销所有匹配的会话, 返回撤销的数量
+func RevokeAll(userID string, clientID string) (int, error) {
+	list, err := List(userID, clientID)
+	if err != nil {
+		return 0, err
+	}
+	for i, s := range list {
+		if err := users.Sessions().RevokeSession(s.ID, s.ExpiresAt); err != nil {
+			return i, err
+		}
+	}
+	return len(list), nil
+}
+
+// IsRevoked 判断会话是否已被撤销
+func IsRevoked(id string) (bool, error) {
+	return users.Sessions().IsSessionRevoked(id)
+}
@@ -0,0 +1,263 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"ripper/internal/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind span 类型, 与 OTLP 中的取值一致
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+const (
+	batchSize     = 512
+	queueSize     = 4096
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second // 单批 span 导出的超时, 避免 collector 无响应时阻塞导出
+)
+
+// Span 一段调用的耗时记录, 未启用链路追踪或未被采样时为 nil 或不记录, 所有方法都可以安全调用
+type Span struct {
+	traceID   [16]byte
+	spanID    [8]byte
+	parentID  [8]byte
+	name      string
+	kind      SpanKind
+	start     time.Time
+	recording bool
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []attr
+	errMsg string
+	ended  bool
+}
+
+type attr struct {
+	key   string
+	value any
+}
+
+// SetAttr 设置属性, 支持字符串、整数、浮点数和布尔值
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attr{key: key, value: value})
+}
+
+// SetError 标记 span 失败
+func (s *Span) SetError(err error) {
+	if s == nil || !s.recording || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMsg = err.Error()
+}
+
+// End 结束 span 并提交导出, 重复调用无效
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt 以指定时间结束 span
+func (s *Span) EndAt(at time.Time) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, at
+	s.mu.Unlock()
+
+	if t := current.Load(); t != nil {
+		t.enqueue(s)
+	}
+}
+
+type spanKey struct{}
+
+// FromContext 获取上下文中的当前 span
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start 创建当前 span 的子 span, 上下文中没有 span 时创建新的链路
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartAt(ctx, name, KindInternal, time.Now())
+}
+
+// StartAt 以指定类型和开始时间创建 span, 用于根据已记录的时间点补充 span
+func StartAt(ctx context.Context, name string, kind SpanKind, start time.Time) (context.Context, *Span) {
+	t := current.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := FromContext(ctx)
+	if parent != nil && !parent.recording {
+		// 未被采样的链路不再创建子 span
+		return ctx, parent
+	}
+
+	span := &Span{name: name, kind: kind, start: start, recording: true}
+	_, _ = rand.Read(span.spanID[:])
+	if parent != nil {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		_, _ = rand.Read(span.traceID[:])
+		span.recording = t.sample()
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// tracer 批量导出已结束的 span
+type tracer struct {
+	exporter exporter
+	service  string
+	ratio    float64
+	queue    chan *Span
+	dropped  atomic.Int64
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+var current atomic.Pointer[tracer]
+
+// Init 根据配置启用链路追踪, 未启用时不创建 span
+// client 用于发送到 collector, 不能使用 Transport 包装, 否则导出请求会继续产生 span
+func Init(cfg config.TracingConfig, client *http.Client) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	var e exporter
+	switch cfg.Exporter {
+	case "file":
+		f, err := newFileExporter(cfg.File)
+		if err != nil {
+			return err
+		}
+		e = f
+	default:
+		e = newHTTPExporter(cfg.Endpoint, client)
+	}
+
+	t := &tracer{
+		exporter: e,
+		service:  cfg.ServiceName,
+		ratio:    cfg.SampleRatio,
+		queue:    make(chan *Span, queueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	current.Store(t)
+	go t.run()
+	return nil
+}
+
+// Close 导出剩余的 span 并停止链路追踪
+func Close() error {
+	t := current.Swap(nil)
+	if t == nil {
+		return nil
+	}
+	close(t.done)
+	<-t.stopped
+	return t.exporter.Close()
+}
+
+// sample 按采样率决定是否记录新的链路
+func (t *tracer) sample() bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	if t.ratio <= 0 {
+		return false
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return false
+	}
+	return float64(n.Int64()) < t.ratio*1_000_000
+}
+
+// enqueue 提交已结束的 span, 队列已满时丢弃
+func (t *tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			slog.Warn("failed to export spans", "spans", len(batch), "error", err)
+		}
+		if dropped := t.dropped.Swap(0); dropped > 0 {
+			slog.Warn("tracing queue is full, spans dropped", "spans", dropped)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.done:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *tracer) export(spans []*Span) error {
+	data, err := encode(t.service, spans)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	return t.exporter.Export(ctx, data)
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Transport 为上游请求记录 span, 包含建立连接、首字节和读取响应流的耗时
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx, span := StartAt(req.Context(), "upstream "+req.Method+" "+req.URL.Host, KindClient, start)
+	if span == nil || !span.recording {
+		return t.base.RoundTrip(req)
+	}
+	// 不记录查询参数, 部分上游 (如 Gemini) 通过查询参数传递 key
+	span.SetAttr("http.method", req.Method)
+	span.SetAttr("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+
+	var mu sync.Mutex
+	var getConn, gotConn, wroteRequest, firstByte time.Time
+	var reused bool
+	mark := func(at *time.Time) {
+		mu.Lock()
+		*at = time.Now()
+		mu.Unlock()
+	}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { mark(&getConn) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			mark(&gotConn)
+			mu.Lock()
+			reused = info.Reused
+			mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&wroteRequest) },
+		GotFirstResponseByte: func() { mark(&firstByte) },
+	})
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !getConn.IsZero() && !gotConn.IsZero() {
+		_, connect := StartAt(ctx, "upstream.connect", KindInternal, getConn)
+		connect.SetAttr("reused", reused)
+		connect.EndAt(gotConn)
+	}
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetError(httpError(resp.Status))
+	}
+	if !firstByte.IsZero() {
+		ttfbStart := wroteRequest
+		if ttfbStart.IsZero() {
+			ttfbStart = start
+		}
+		_, ttfb := StartAt(ctx, "upstream.ttfb", KindInternal, ttfbStart)
+		ttfb.EndAt(firstByte)
+	} else {
+		firstByte = time.Now()
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, ctx: ctx, span: span, start: firstByte}
+	return resp, nil
+}
+
+// httpError 上游返回的错误状态
+type httpError string
+
+func (e httpError) Error() string { return string(e) }
+
+// tracedBody 响应体关闭时记录 upstream.stream 并结束上游请求的 span
+type tracedBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	span  *Span
+	start time.Time
+	size  int64
+	once  sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if err != nil && err != io.EOF {
+		b.span.SetError(err)
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		_, stream := StartAt(b.ctx, "upstream.stream", KindInternal, b.start)
+		stream.SetAttr("bytes", b.size)
+		stream.End()
+		b.span.End()
+	})
+	return err
+}
@@ -0,0 +1,182 @@
+package usage
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxBodySize 非流式响应最多缓存的字节数, 超出后不再解析
+const maxBodySize = 4 << 20
+
+// Result 一次请求的 token 用量
+type Result struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	CachedTokens     int  // 提示词中命中上游缓存的 token 数, 包含在 PromptTokens 中
+	Estimated        bool // 上游未返回 usage, 由 EstimateTokens 估算
+}
+
+// Total 总 token 数
+func (r Result) Total() int {
+	return r.PromptTokens + r.CompletionTokens
+}
+
+// Meter 从写给插件的响应中解析 token 用量, 同时支持 SSE 流和普通 json 响应
+// 响应已由各上游适配器转换为 OpenAI 格式, 因此只需要解析一种格式
+type Meter struct {
+	line       []byte
+	body       bytes.Buffer
+	stream     bool
+	overflow   bool
+	model      string
+	usage      gjson.Result
+	completion strings.Builder
+}
+
+// Write 写入响应数据, 按行解析 SSE 事件
+func (m *Meter) Write(p []byte) (int, error) {
+	if !m.stream && !m.overflow {
+		if m.body.Len()+len(p) > maxBodySize {
+			m.overflow = true
+			m.body.Reset()
+		} else {
+			m.body.Write(p)
+		}
+	}
+
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			m.line = append(m.line, data...)
+			break
+		}
+		m.line = append(m.line, data[:i]...)
+		m.parseLine(m.line)
+		m.line = m.line[:0]
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+// Observe 记录未写给插件的 chunk, 如客户端未请求时被丢弃的 usage chunk
+func (m *Meter) Observe(chunk []byte) {
+	m.parseChunk(gjson.ParseBytes(chunk))
+}
+
+// parseLine 解析一行 SSE 数据
+func (m *Meter) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	if !m.stream {
+		m.stream = true
+		m.body.Reset()
+	}
+	payload := bytes.TrimSpace(line[len("data:"):])
+	if len(payload) == 0 || payload[0] != '{' {
+		return
+	}
+	m.parseChunk(gjson.ParseBytes(payload))
+}
+
+// parseChunk 记录模型名称、usage 和补全内容
+func (m *Meter) parseChunk(chunk gjson.Result) {
+	if model := chunk.Get("model").String(); model != "" {
+		m.model = model
+	}
+	if usage := chunk.Get("usage"); usage.IsObject() {
+		m.usage = usage
+	}
+	chunk.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		m.completion.WriteString(choice.Get("text").String())
+		m.completion.WriteString(choice.Get("delta.content").String())
+		m.completion.WriteString(choice.Get("message.content").String())
+		return true
+	})
+}
+
+// Finish 结束解析并返回用量, 上游未返回 usage 时根据请求内容和补全内容估算
+func (m *Meter) Finish(requestBody []byte) Result {
+	if len(m.line) > 0 {
+		m.parseLine(m.line)
+		m.line = m.line[:0]
+	}
+	if !m.stream && !m.overflow && gjson.ValidBytes(m.body.Bytes()) {
+		m.parseChunk(gjson.ParseBytes(m.body.Bytes()))
+	}
+
+	result := Result{Model: m.model}
+	if result.Model == "" {
+		result.Model = gjson.GetBytes(requestBody, "model").String()
+	}
+	if m.usage.Exists() {
+		result.PromptTokens = int(m.usage.Get("prompt_tokens").Int())
+		result.CompletionTokens = int(m.usage.Get("completion_tokens").Int())
+		// OpenAI 使用 prompt_tokens_details.cached_tokens, DeepSeek 使用 prompt_cache_hit_tokens
+		result.CachedTokens = int(m.usage.Get("prompt_tokens_details.cached_tokens").Int())
+		if result.CachedTokens == 0 {
+			result.CachedTokens = int(m.usage.Get("prompt_cache_hit_tokens").Int())
+		}
+		if result.Total() > 0 {
+			return result
+		}
+	}
+
+	result.Estimated = true
+	result.PromptTokens = EstimateTokens(PromptText(requestBody))
+	result.CompletionTokens = EstimateTokens(m.completion.String())
+	return result
+}
+
+// PromptText 提取请求中的提示词, 支持代码补全、对话和 Embedding 请求
+func PromptText(body []byte) string {
+	var sb strings.Builder
+	request := gjson.ParseBytes(body)
+	sb.WriteString(request.Get("prompt").String())
+	sb.WriteString(request.Get("suffix").String())
+	request.Get("messages").ForEach(func(_, message gjson.Result) bool {
+		content := message.Get("content")
+		if content.IsArray() {
+			content.ForEach(func(_, part gjson.Result) bool {
+				sb.WriteString(part.Get("text").String())
+				return true
+			})
+		} else {
+			sb.WriteString(content.String())
+		}
+		return true
+	})
+	input := request.Get("input")
+	if input.IsArray() {
+		input.ForEach(func(_, item gjson.Result) bool {
+			sb.WriteString(item.String())
+			return true
+		})
+	} else {
+		sb.WriteString(input.String())
+	}
+	return sb.String()
+}
+
+// EstimateTokens 估算文本的 token 数
+// 中日韩文字按每个字 1 个 token 计算, 其余字符按每 4 个字符 1 个 token 计算, 与常见 BPE 分词器的结果接近
+func EstimateTokens(text string) int {
+	cjk, others := 0, 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			others++
+		}
+	}
+	return cjk + (others+3)/4
+}
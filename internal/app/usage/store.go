@@ -0,0 +1,159 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// dailyBucket 按天汇总的用量, 键为 日期|用户|模型|接口
+var dailyBucket = []byte("daily")
+
+// dateLayout 用量记录的日期格式
+const dateLayout = "2006-01-02"
+
+// Record 某个用户在某天通过某个接口使用某个模型的用量汇总
+type Record struct {
+	Date             string `json:"date"`
+	User             string `json:"user"`
+	Model            string `json:"model"`
+	Endpoint         string `json:"endpoint"`
+	Requests         int64  `json:"requests"`
+	EstimatedCount   int64  `json:"estimated_requests"` // 其中按估算计算 token 数的请求数
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+}
+
+// Filter 查询条件, 为空时不过滤, 日期格式为 2006-01-02
+type Filter struct {
+	From     string
+	To       string
+	User     string
+	Model    string
+	Endpoint string
+}
+
+// Store 基于 bbolt 的用量存储
+type Store struct {
+	db      *bolt.DB
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup // 未完成的异步写入, 关闭前等待写完
+}
+
+// Open 打开或创建用量数据文件
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create usage store directory %s: %v", dir, err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage store %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(dailyBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close 等待未完成的异步写入后关闭用量数据文件, 关闭后的 AddAsync 会被丢弃
+func (s *Store) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.pending.Wait()
+	return s.db.Close()
+}
+
+// AddAsync 在后台累加一次请求的用量, 不阻塞响应, 写入失败时记录日志
+func (s *Store) AddAsync(ctx context.Context, at time.Time, user string, endpoint string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		slog.WarnContext(ctx, "usage store closed, dropping usage record", "user", user, "endpoint", endpoint)
+		return
+	}
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := s.Add(at, user, endpoint, result); err != nil {
+			slog.WarnContext(ctx, "failed to record usage", "error", err)
+		}
+	}()
+}
+
+// Add 累加一次请求的用量, 并发写入会合并到同一个事务中
+func (s *Store) Add(at time.Time, user string, endpoint string, result Result) error {
+	r := Record{
+		Date:     at.Format(dateLayout),
+		User:     user,
+		Model:    result.Model,
+		Endpoint: endpoint,
+	}
+	key := []byte(strings.Join([]string{r.Date, r.User, r.Model, r.Endpoint}, "|"))
+
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dailyBucket)
+		if data := bucket.Get(key); data != nil {
+			if err := json.Unmarshal(data, &r); err != nil {
+				return err
+			}
+		}
+		r.Requests++
+		if result.Estimated {
+			r.EstimatedCount++
+		}
+		r.PromptTokens += int64(result.PromptTokens)
+		r.CompletionTokens += int64(result.CompletionTokens)
+		r.TotalTokens += int64(result.Total())
+
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+}
+
+// Query 查询用量记录, 按日期、用户、模型、接口排序
+func (s *Store) Query(f Filter) ([]*Record, error) {
+	list := make([]*Record, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(dailyBucket).Cursor()
+		for k, v := c.Seek([]byte(f.From)); k != nil; k, v = c.Next() {
+			// 键以日期开头, 超过结束日期后不再遍历
+			if f.To != "" && string(k[:len(dateLayout)]) > f.To {
+				break
+			}
+			r := &Record{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			if (f.User == "" || r.User == f.User) && (f.Model == "" || r.Model == f.Model) && (f.Endpoint == "" || r.Endpoint == f.Endpoint) {
+				list = append(list, r)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"log/slog"
+	"ripper/internal/config"
+)
+
+// store 全局用量存储, 未启用用量统计时为 nil
+var store *Store
+
+// Init 启用用量统计时打开用量存储, 修改后需要重启才能生效
+func Init(cfg config.UsageConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	s, err := Open(cfg.Path)
+	if err != nil {
+		return err
+	}
+	store = s
+	slog.Info("usage accounting enabled", "path", cfg.Path)
+	return nil
+}
+
+// Default 获取全局用量存储, 未启用用量统计时返回 nil
+func Default() *Store {
+	return store
+}
+
+// Close 关闭全局用量存储
+func Close() error {
+	if store == nil {
+		return nil
+	}
+	return store.Close()
+}
@@ -0,0 +1,128 @@
+package users
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucket = []byte("sessions")
+	revokedBucket  = []byte("revoked_sessions") // 会话 ID -> 撤销记录的过期时间, 零值表示永久保留
+)
+
+// Session 登录会话, 对应一次插件登录签发的 JWT, ID 即 JWT 的 jti
+type Session struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id,omitempty"`
+	ClientID        string    `json:"client_id"`
+	UserDisplayName string    `json:"user_display_name,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// CreateSession 记录登录会话
+func (s *Store) CreateSession(sess *Session) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(sessionsBucket), sess.ID, sess)
+	})
+}
+
+// GetSession 获取登录会话, 不存在时返回 ErrNotFound
+func (s *Store) GetSession(id string) (*Session, error) {
+	sess := &Session{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(sessionsBucket), id, sess)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// ListSessions 获取未过期的会话, userID 和 clientID 为空时不过滤, 按创建时间排序
+func (s *Store) ListSessions(userID string, clientID string) ([]*Session, error) {
+	now := time.Now()
+	list := make([]*Session, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(_, v []byte) error {
+			sess := &Session{}
+			if err := json.Unmarshal(v, sess); err != nil {
+				return err
+			}
+			if sess.ExpiresAt.After(now) && (userID == "" || sess.UserID == userID) && (clientID == "" || sess.ClientID == clientID) {
+				list = append(list, sess)
+			}
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, err
+}
+
+// RevokeSession 写入撤销记录并删除会话, 撤销记录保留到 expiresAt, 零值表示永久保留
+// 会话记录不存在时同样写入撤销记录
+func (s *Store) RevokeSession(id string, expiresAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := put(tx.Bucket(revokedBucket), id, expiresAt); err != nil {
+			return err
+		}
+		return tx.Bucket(sessionsBucket).Delete([]byte(id))
+	})
+}
+
+// IsSessionRevoked 判断会话是否已被撤销
+func (s *Store) IsSessionRevoked(id string) (bool, error) {
+	var revoked bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		revoked = tx.Bucket(revokedBucket).Get([]byte(id)) != nil
+		return nil
+	})
+	return revoked, err
+}
+
+// PurgeSessions 删除已过期的会话和撤销记录, 返回删除的数量
+func (s *Store) PurgeSessions(now time.Time) (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var expired [][]byte
+		err := tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			sess := &Session{}
+			if err := json.Unmarshal(v, sess); err != nil || !sess.ExpiresAt.After(now) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := tx.Bucket(sessionsBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+		count += len(expired)
+
+		expired = expired[:0]
+		err = tx.Bucket(revokedBucket).ForEach(func(k, v []byte) error {
+			var expiresAt time.Time
+			if err := json.Unmarshal(v, &expiresAt); err != nil || (!expiresAt.IsZero() && !expiresAt.After(now)) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := tx.Bucket(revokedBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+		count += len(expired)
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,206 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	usersBucket      = []byte("users")
+	tokensBucket     = []byte("tokens")
+	tokenHashBucket  = []byte("token_hashes") // token 哈希 -> token ID
+	userNamesBucket  = []byte("user_names")   // 用户名 -> 用户 ID
+	allBuckets       = [][]byte{usersBucket, tokensBucket, tokenHashBucket, userNamesBucket, sessionsBucket, revokedBucket}
+	ErrNotFound      = errors.New("not found")
+	ErrNameExists    = errors.New("user name already exists")
+	ErrInvalidToken  = errors.New("invalid access token")
+	ErrTokenExpired  = errors.New("access token expired")
+	ErrUserDisabled  = errors.New("user is disabled")
+	ErrNameRequired  = errors.New("user name is required")
+	ErrTokenNotOwned = errors.New("token does not belong to the user")
+)
+
+// User 用户账号
+type User struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Disabled    bool      `json:"disabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Store 基于 bbolt 的用户存储
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开或创建用户数据文件
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create user store directory %s: %v", dir, err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user store %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close 关闭用户数据文件
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// CreateUser 创建用户, 用户名不能重复
+func (s *Store) CreateUser(name string, displayName string) (*User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrNameRequired
+	}
+
+	u := &User{
+		ID:          newID(),
+		Name:        name,
+		DisplayName: displayName,
+		CreatedAt:   time.Now(),
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		names := tx.Bucket(userNamesBucket)
+		if names.Get([]byte(name)) != nil {
+			return ErrNameExists
+		}
+		if err := names.Put([]byte(name), []byte(u.ID)); err != nil {
+			return err
+		}
+		return put(tx.Bucket(usersBucket), u.ID, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ListUsers 获取所有用户, 按创建时间排序
+func (s *Store) ListUsers() ([]*User, error) {
+	var list []*User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(_, v []byte) error {
+			u := &User{}
+			if err := json.Unmarshal(v, u); err != nil {
+				return err
+			}
+			list = append(list, u)
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, err
+}
+
+// GetUser 获取用户
+func (s *Store) GetUser(id string) (*User, error) {
+	u := &User{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(usersBucket), id, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// UpdateUser 修改用户的显示名称和禁用状态, 参数为 nil 时不修改
+func (s *Store) UpdateUser(id string, displayName *string, disabled *bool) (*User, error) {
+	u := &User{}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if err := get(bucket, id, u); err != nil {
+			return err
+		}
+		if displayName != nil {
+			u.DisplayName = *displayName
+		}
+		if disabled != nil {
+			u.Disabled = *disabled
+		}
+		return put(bucket, id, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// DeleteUser 删除用户及其所有 token
+func (s *Store) DeleteUser(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		u := &User{}
+		if err := get(tx.Bucket(usersBucket), id, u); err != nil {
+			return err
+		}
+
+		tokens, err := userTokens(tx, id)
+		if err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if err := deleteToken(tx, t); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(userNamesBucket).Delete([]byte(u.Name)); err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Delete([]byte(id))
+	})
+}
+
+// newID 生成记录 ID
+func newID() string {
+	id, _ := uuid.NewV4()
+	return strings.ReplaceAll(id.String(), "-", "")[:16]
+}
+
+// get 读取并解析记录, 不存在时返回 ErrNotFound
+func get(bucket *bolt.Bucket, id string, v interface{}) error {
+	data := bucket.Get([]byte(id))
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// put 编码并写入记录
+func put(bucket *bolt.Bucket, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(id), data)
+}
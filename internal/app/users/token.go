@@ -0,0 +1,202 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// TokenPrefix 访问令牌前缀, 用于区分访问令牌和 JWT
+const TokenPrefix = "cpx_"
+
+// lastUsedInterval 最近使用时间的更新间隔, 避免每个请求都写入数据文件
+const lastUsedInterval = time.Minute
+
+// Token 用户的访问令牌, 只保存哈希值, 明文仅在创建时返回一次
+type Token struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name,omitempty"`
+	Hint       string     `json:"hint"` // 令牌的前几位, 便于识别
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// storedToken 持久化的令牌, 哈希值不对外输出
+type storedToken struct {
+	Token
+	Hash string `json:"hash"`
+}
+
+// IsToken 判断是否为访问令牌格式
+func IsToken(value string) bool {
+	return strings.HasPrefix(value, TokenPrefix)
+}
+
+// hashToken 计算令牌哈希
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken 为用户创建访问令牌, expiresAt 为 nil 表示永不过期
+// 返回的明文令牌只能在此时获取
+func (s *Store) CreateToken(userID string, name string, expiresAt *time.Time) (string, *Token, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	plain := TokenPrefix + hex.EncodeToString(buf)
+
+	t := &storedToken{
+		Token: Token{
+			ID:        newID(),
+			UserID:    userID,
+			Name:      name,
+			Hint:      plain[:len(TokenPrefix)+6],
+			CreatedAt: time.Now(),
+			ExpiresAt: expiresAt,
+		},
+		Hash: hashToken(plain),
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := get(tx.Bucket(usersBucket), userID, &User{}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(tokenHashBucket).Put([]byte(t.Hash), []byte(t.ID)); err != nil {
+			return err
+		}
+		return put(tx.Bucket(tokensBucket), t.ID, t)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, &t.Token, nil
+}
+
+// ListTokens 获取用户的所有访问令牌, 按创建时间排序
+func (s *Store) ListTokens(userID string) ([]*Token, error) {
+	var list []*Token
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if err := get(tx.Bucket(usersBucket), userID, &User{}); err != nil {
+			return err
+		}
+		tokens, err := userTokens(tx, userID)
+		for _, t := range tokens {
+			list = append(list, &t.Token)
+		}
+		return err
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, err
+}
+
+// RevokeToken 撤销用户的访问令牌
+func (s *Store) RevokeToken(userID string, tokenID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		t := &storedToken{}
+		if err := get(tx.Bucket(tokensBucket), tokenID, t); err != nil {
+			return err
+		}
+		if t.UserID != userID {
+			return ErrTokenNotOwned
+		}
+		return deleteToken(tx, t)
+	})
+}
+
+// Authenticate 校验明文访问令牌, 返回令牌所属的用户
+func (s *Store) Authenticate(plain string) (*User, *Token, error) {
+	if !IsToken(plain) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	var tokenID string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(tokenHashBucket).Get([]byte(hashToken(plain)))
+		if id == nil {
+			return ErrInvalidToken
+		}
+		tokenID = string(id)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.Check(tokenID)
+}
+
+// Check 校验令牌 ID 是否仍然有效, 用于校验由访问令牌换取的 JWT 和 Copilot token
+func (s *Store) Check(tokenID string) (*User, *Token, error) {
+	t := &storedToken{}
+	u := &User{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if err := get(tx.Bucket(tokensBucket), tokenID, t); err != nil {
+			return ErrInvalidToken
+		}
+		if err := get(tx.Bucket(usersBucket), t.UserID, u); err != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return nil, nil, ErrTokenExpired
+	}
+	if u.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > lastUsedInterval {
+		s.touch(tokenID, now)
+	}
+	return u, &t.Token, nil
+}
+
+// touch 更新令牌的最近使用时间
+func (s *Store) touch(tokenID string, now time.Time) {
+	s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+		t := &storedToken{}
+		if err := get(bucket, tokenID, t); err != nil {
+			return nil
+		}
+		t.LastUsedAt = &now
+		return put(bucket, tokenID, t)
+	})
+}
+
+// userTokens 获取用户的所有令牌
+func userTokens(tx *bolt.Tx, userID string) ([]*storedToken, error) {
+	var tokens []*storedToken
+	err := tx.Bucket(tokensBucket).ForEach(func(_, v []byte) error {
+		t := &storedToken{}
+		if err := json.Unmarshal(v, t); err != nil {
+			return err
+		}
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+		return nil
+	})
+	return tokens, err
+}
+
+// deleteToken 删除令牌及其哈希索引
+func deleteToken(tx *bolt.Tx, t *storedToken) error {
+	if err := tx.Bucket(tokenHashBucket).Delete([]byte(t.Hash)); err != nil {
+		return err
+	}
+	return tx.Bucket(tokensBucket).Delete([]byte(t.ID))
+}
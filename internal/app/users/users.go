@@ -0,0 +1,85 @@
+package users
+
+import (
+	"log/slog"
+	"ripper/internal/config"
+	"time"
+)
+
+// purgeInterval 清理过期会话和撤销记录的间隔
+const purgeInterval = time.Hour
+
+var (
+	// store 全局数据存储, 保存用户、访问令牌及登录会话
+	store *Store
+	// enabled 是否启用用户模式
+	enabled bool
+	stop    chan struct{}
+	done    chan struct{}
+)
+
+// Init 打开数据存储并定期清理过期的登录会话, 修改后需要重启才能生效
+// 登录会话的撤销记录需要在重启后继续生效, 因此未启用用户模式时同样会打开
+func Init(cfg config.AuthConfig) error {
+	s, err := Open(cfg.UserDBPath)
+	if err != nil {
+		return err
+	}
+	store = s
+	enabled = cfg.UserAuth
+	if enabled {
+		slog.Info("user auth enabled", "path", cfg.UserDBPath)
+	}
+
+	stop, done = make(chan struct{}), make(chan struct{})
+	go purge(s, stop, done)
+	return nil
+}
+
+// purge 启动时及每隔 purgeInterval 清理过期的会话和撤销记录
+func purge(s *Store, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.PurgeSessions(time.Now()); err != nil {
+			slog.Warn("purge expired sessions failed", "error", err)
+		} else if n > 0 {
+			slog.Debug("purged expired sessions", "count", n)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Default 获取全局用户存储, 未启用用户模式时返回 nil
+func Default() *Store {
+	if !enabled {
+		return nil
+	}
+	return store
+}
+
+// Sessions 获取保存登录会话的存储, 不论是否启用用户模式都可用
+func Sessions() *Store {
+	return store
+}
+
+// Enabled 是否启用用户模式
+func Enabled() bool {
+	return enabled && store != nil
+}
+
+// Close 关闭全局数据存储
+func Close() error {
+	if store == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	return store.Close()
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket 缓存数据所在的 bucket
+var boltBucket = []byte("cache")
+
+// boltEntry 持久化的缓存条目
+type boltEntry struct {
+	Value     []byte `json:"value"`
+	ExpiresAt int64  `json:"expires_at,omitempty"` // 过期时间戳(毫秒), 0 表示永久
+}
+
+// expired 是否已过期
+func (e *boltEntry) expired(now int64) bool {
+	return e.ExpiresAt > 0 && now > e.ExpiresAt
+}
+
+// BoltCache 基于 bbolt 的持久化缓存, 重启后设备码绑定和授权信息不会丢失
+type BoltCache struct {
+	db       *bolt.DB
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBoltCache 打开或创建缓存文件, 并清理已过期的条目
+// cleanupInterval 后台清理过期条目的间隔, 0 表示仅在启动时清理
+func NewBoltCache(path string, cleanupInterval time.Duration) (*BoltCache, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory %s: %v", dir, err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache file %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	b := &BoltCache{db: db, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := b.purge(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if cleanupInterval > 0 {
+		go b.janitor(cleanupInterval)
+	} else {
+		close(b.done)
+	}
+	return b, nil
+}
+
+// Get 获取缓存, 不存在或已过期时返回 nil
+func (b *BoltCache) Get(ctx context.Context, key string) ([]byte, error) {
+	values, err := b.MGet(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// Set 设置缓存中的值，并指定过期时间（秒）, 0 表示默认半小时, -1 表示永久缓存
+func (b *BoltCache) Set(ctx context.Context, key string, value []byte, ttl int) error {
+	return b.MSet(ctx, map[string][]byte{key: value}, ttl)
+}
+
+// SetNX 仅在键不存在或已过期时设置
+func (b *BoltCache) SetNX(ctx context.Context, key string, value []byte, ttl int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	ok := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if entry := readEntry(bucket, key, time.Now().UnixMilli()); entry != nil {
+			return nil
+		}
+		ok = true
+		return writeEntry(bucket, key, value, ttl)
+	})
+	return ok && err == nil, err
+}
+
+// GetDel 在同一事务中获取并删除键
+func (b *BoltCache) GetDel(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if entry := readEntry(bucket, key, time.Now().UnixMilli()); entry != nil {
+			value = entry.Value
+		}
+		return bucket.Delete([]byte(key))
+	})
+	return value, err
+}
+
+// MGet 批量获取, 已过期的键视为不存在, 由后台清理或重启时删除
+func (b *BoltCache) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(keys))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		now := time.Now().UnixMilli()
+		for i, key := range keys {
+			if entry := readEntry(bucket, key, now); entry != nil {
+				values[i] = entry.Value
+			}
+		}
+		return nil
+	})
+	return values, err
+}
+
+// MSet 在同一事务中批量设置
+func (b *BoltCache) MSet(ctx context.Context, values map[string][]byte, ttl int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for key, value := range values {
+			if err := writeEntry(bucket, key, value, ttl); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Exist 判断缓存是否存在
+func (b *BoltCache) Exist(ctx context.Context, key string) (bool, error) {
+	value, err := b.Get(ctx, key)
+	return value != nil, err
+}
+
+// Del 删除缓存
+func (b *BoltCache) Del(ctx context.Context, keys ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, key := range keys {
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IncrBy 在同一事务中读取并增加计数器
+func (b *BoltCache) IncrBy(ctx context.Context, key string, n int64, ttl int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var value int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		entry := readEntry(bucket, key, time.Now().UnixMilli())
+		if entry == nil {
+			value = n
+			return writeEntry(bucket, key, []byte(strconv.FormatInt(value, 10)), ttl)
+		}
+
+		current, err := parseCounter(key, entry.Value)
+		if err != nil {
+			return err
+		}
+		value = current + n
+		entry.Value = []byte(strconv.FormatInt(value, 10))
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), data)
+	})
+	return value, err
+}
+
+// Keys 按前缀遍历键
+func (b *BoltCache) Keys(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		now := time.Now().UnixMilli()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			var entry boltEntry
+			if err := json.Unmarshal(v, &entry); err != nil || entry.expired(now) {
+				continue
+			}
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// Close 停止后台清理并关闭缓存文件
+func (b *BoltCache) Close() error {
+	b.stopOnce.Do(func() { close(b.stop) })
+	<-b.done
+	return b.db.Close()
+}
+
+// janitor 定时清理过期条目, 避免限流计数等不再读取的键让缓存文件持续增长
+func (b *BoltCache) janitor(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.purge(); err != nil {
+				slog.Warn("purge expired cache entries failed", "error", err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// readEntry 读取未过期的缓存条目, 不存在、已过期或无法解析时返回 nil
+func readEntry(bucket *bolt.Bucket, key string, now int64) *boltEntry {
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+
+	entry := &boltEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.expired(now) {
+		return nil
+	}
+	return entry
+}
+
+// writeEntry 写入缓存条目
+func writeEntry(bucket *bolt.Bucket, key string, value []byte, ttl int) error {
+	data, err := json.Marshal(boltEntry{Value: value, ExpiresAt: expireAt(ttl)})
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
+
+// purge 清理所有已过期的条目
+func (b *BoltCache) purge() error {
+	now := time.Now().UnixMilli()
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var entry boltEntry
+			if err := json.Unmarshal(v, &entry); err != nil || entry.expired(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 编译时检查
+var _ Cacheable = (*BoltCache)(nil)
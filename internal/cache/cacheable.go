@@ -1,8 +1,51 @@
 package cache
 
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Cacheable 缓存实现需要支持的操作, 值统一为 []byte, 由泛型接口负责编解码
+// ttl 单位为秒, 0 表示默认半小时, -1 表示永久缓存; 键不存在时返回 nil
 type Cacheable interface {
-	Set(key string, value interface{}, ttl int) error
-	Get(key string) (interface{}, error)
-	Exist(key string) (bool, error)
-	Del(key string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl int) error
+	// SetNX 仅在键不存在时设置, 返回是否设置成功
+	SetNX(ctx context.Context, key string, value []byte, ttl int) (bool, error)
+	// GetDel 获取并删除键
+	GetDel(ctx context.Context, key string) ([]byte, error)
+	// MGet 批量获取, 结果与 keys 一一对应, 不存在的键为 nil
+	MGet(ctx context.Context, keys ...string) ([][]byte, error)
+	MSet(ctx context.Context, values map[string][]byte, ttl int) error
+	Exist(ctx context.Context, key string) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+	// IncrBy 将键的整数值增加 n 并返回增加后的值, 键不存在时从 0 开始并使用 ttl 作为过期时间, 已存在的键不修改过期时间
+	IncrBy(ctx context.Context, key string, n int64, ttl int) (int64, error)
+	// Keys 获取指定前缀的所有未过期的键, 顺序不固定
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// parseCounter 解析计数器的值, 计数器以十进制字符串保存, 与 redis INCRBY 一致
+func parseCounter(key string, value []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not an integer", key)
+	}
+	return n, nil
+}
+
+// defaultTTL 未指定过期时间时默认半小时
+const defaultTTL = 30 * 60
+
+// expireAt 计算过期时间戳(毫秒), 0 表示永久
+func expireAt(ttl int) int64 {
+	if ttl == -1 {
+		return 0
+	}
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return time.Now().UnixMilli() + int64(ttl)*1000
 }
@@ -1,100 +1,294 @@
 package cache
 
 import (
-	"fmt"
+	"container/list"
+	"context"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-// MemoryMap 用于内存缓存
+// MemoryMap 用于内存缓存, 支持后台清理过期键和按最近最少使用淘汰
+// IncrBy 创建的计数器 (如限流计数) 不参与淘汰, 仅在过期或删除时清理, 避免缓存压力导致计数被重置
 type MemoryMap struct {
-	cache       map[string]interface{}
-	expirations map[string]int64
-	mu          sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List // 队首为最近使用的键
+	counters   *list.List // 不参与淘汰的计数器
+	maxEntries int
+	stats      MemoryStats
+	stop       chan struct{}
+	stopOnce   sync.Once
+	mu         sync.Mutex
 }
 
-func NewMemoryMap() *MemoryMap {
+// memoryEntry 缓存条目
+type memoryEntry struct {
+	key        string
+	value      []byte
+	expiration int64 // 过期时间戳(毫秒), 0 表示永久
+	counter    bool  // 是否为计数器, 计数器保存在 counters 中
+}
+
+// MemoryStats 内存缓存统计信息
+type MemoryStats struct {
+	Entries     int   `json:"entries"`
+	MaxEntries  int   `json:"max_entries"`
+	Hits        int64 `json:"hits"`
+	Misses      int64 `json:"misses"`
+	Evictions   int64 `json:"evictions"`   // 超过最大数量被淘汰的键
+	Expirations int64 `json:"expirations"` // 过期被清理的键
+}
+
+// NewMemoryMap 创建内存缓存
+// maxEntries 最大键数量, 超过时淘汰最近最少使用的键, 0 表示不限制
+// cleanupInterval 后台清理过期键的间隔, 0 表示仅在读取时清理
+func NewMemoryMap(maxEntries int, cleanupInterval time.Duration) *MemoryMap {
 	m := &MemoryMap{}
-	m.init()
+	m.init(maxEntries)
+	if cleanupInterval > 0 {
+		go m.janitor(cleanupInterval)
+	}
 	return m
 }
 
 // init 初始化 MemoryMap 的缓存
-func (m *MemoryMap) init() {
-	m.cache = make(map[string]interface{})
-	m.expirations = make(map[string]int64)
+func (m *MemoryMap) init(maxEntries int) {
+	m.items = make(map[string]*list.Element)
+	m.lru = list.New()
+	m.counters = list.New()
+	m.maxEntries = maxEntries
+	m.stop = make(chan struct{})
 }
 
-func (m *MemoryMap) Get(key string) (interface{}, error) {
+func (m *MemoryMap) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.get(key), nil
+}
+
+// Set 设置缓存中的值，并指定过期时间（秒）
+func (m *MemoryMap) Set(_ context.Context, key string, value []byte, ttl int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	expiration, exists := m.expirations[key]
-	currentTime := time.Now().UnixMilli()
-	if exists && currentTime > expiration {
-		// 键已过期，删除并返回 nil
-		fmt.Printf("Get: key=%s has expired, deleting...\n", key)
-		delete(m.cache, key)
-		delete(m.expirations, key)
-		return nil, nil
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryMap) SetNX(_ context.Context, key string, value []byte, ttl int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lookup(key); ok {
+		return false, nil
 	}
+	m.set(key, value, ttl)
+	return true, nil
+}
 
-	value, ok := m.cache[key]
-	if !ok {
-		return nil, nil
+func (m *MemoryMap) GetDel(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value := m.get(key)
+	if value != nil {
+		m.remove(m.items[key])
 	}
 	return value, nil
 }
 
-// Set 设置缓存中的值，并指定过期时间（秒）
-func (m *MemoryMap) Set(key string, value interface{}, ttl int) error {
+func (m *MemoryMap) MGet(_ context.Context, keys ...string) ([][]byte, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.cache[key] = value
-	if ttl == 0 {
-		// 默认半小时
-		ttl = 30 * 60
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = m.get(key)
 	}
+	return values, nil
+}
 
-	if ttl == -1 {
-		// -1 表示永久缓存，不设置过期时间
-		delete(m.expirations, key)
-	} else {
-		expiration := time.Now().UnixMilli() + int64(ttl*1000)
-		m.expirations[key] = expiration
+func (m *MemoryMap) MSet(_ context.Context, values map[string][]byte, ttl int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, value := range values {
+		m.set(key, value, ttl)
 	}
 	return nil
 }
 
-func (m *MemoryMap) Exist(key string) (bool, error) {
+func (m *MemoryMap) Exist(_ context.Context, key string) (bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	expiration, exists := m.expirations[key]
-	currentTime := time.Now().UnixMilli()
-	if exists && currentTime > expiration {
-		// 键已过期，删除并返回 nil
-		fmt.Printf("Get: key=%s has expired, deleting...\n", key)
-		delete(m.cache, key)
-		delete(m.expirations, key)
+	_, ok := m.lookup(key)
+	return ok, nil
+}
+
+func (m *MemoryMap) Del(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := m.items[key]; ok {
+			m.remove(el)
+		}
 	}
+	return nil
+}
 
-	_, ok := m.cache[key]
-	return ok, nil
+func (m *MemoryMap) IncrBy(_ context.Context, key string, n int64, ttl int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		m.items[key] = m.counters.PushFront(&memoryEntry{
+			key:        key,
+			value:      []byte(strconv.FormatInt(n, 10)),
+			expiration: expireAt(ttl),
+			counter:    true,
+		})
+		return n, nil
+	}
+	value, err := parseCounter(key, entry.value)
+	if err != nil {
+		return 0, err
+	}
+	value += n
+	entry.value = []byte(strconv.FormatInt(value, 10))
+	return value, nil
 }
 
-func (m *MemoryMap) Del(key string) error {
+func (m *MemoryMap) Keys(_ context.Context, prefix string) ([]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, ok := m.cache[key]; !ok {
-		return nil
+	var keys []string
+	now := time.Now().UnixMilli()
+	for key, el := range m.items {
+		entry := el.Value.(*memoryEntry)
+		if strings.HasPrefix(key, prefix) && (entry.expiration == 0 || now <= entry.expiration) {
+			keys = append(keys, key)
+		}
 	}
-	delete(m.cache, key)
-	delete(m.expirations, key)
+	return keys, nil
+}
+
+// Stats 获取缓存统计信息
+func (m *MemoryMap) Stats() MemoryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	stats.Entries = m.lru.Len() + m.counters.Len()
+	stats.MaxEntries = m.maxEntries
+	return stats
+}
+
+// Close 停止后台清理
+func (m *MemoryMap) Close() error {
+	m.stopOnce.Do(func() { close(m.stop) })
 	return nil
 }
 
+// get 获取键的值并记录命中情况, 调用方需持有锁
+func (m *MemoryMap) get(key string) []byte {
+	entry, ok := m.lookup(key)
+	if !ok {
+		m.stats.Misses++
+		return nil
+	}
+	m.stats.Hits++
+	return entry.value
+}
+
+// set 设置键的值, 超过最大数量时淘汰最近最少使用的键, 计数器不计入数量, 调用方需持有锁
+func (m *MemoryMap) set(key string, value []byte, ttl int) {
+	expiration := expireAt(ttl)
+	if el, ok := m.items[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiration = expiration
+		if !entry.counter {
+			m.lru.MoveToFront(el)
+		}
+		return
+	}
+
+	m.items[key] = m.lru.PushFront(&memoryEntry{key: key, value: value, expiration: expiration})
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+		m.stats.Evictions++
+	}
+}
+
+// lookup 查找未过期的键并标记为最近使用, 已过期的键会被删除, 调用方需持有锁
+func (m *MemoryMap) lookup(key string) (*memoryEntry, bool) {
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expiration > 0 && time.Now().UnixMilli() > entry.expiration {
+		m.remove(el)
+		m.stats.Expirations++
+		return nil, false
+	}
+	if !entry.counter {
+		m.lru.MoveToFront(el)
+	}
+	return entry, true
+}
+
+// remove 删除缓存条目, 调用方需持有锁
+func (m *MemoryMap) remove(el *list.Element) {
+	entry := el.Value.(*memoryEntry)
+	if entry.counter {
+		m.counters.Remove(el)
+	} else {
+		m.lru.Remove(el)
+	}
+	delete(m.items, entry.key)
+}
+
+// janitor 定时清理过期键, 避免不再读取的键一直占用内存
+func (m *MemoryMap) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.deleteExpired()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// deleteExpired 删除所有已过期的键
+func (m *MemoryMap) deleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	for _, l := range []*list.List{m.lru, m.counters} {
+		for el := l.Back(); el != nil; {
+			prev := el.Prev()
+			entry := el.Value.(*memoryEntry)
+			if entry.expiration > 0 && now > entry.expiration {
+				m.remove(el)
+				m.stats.Expirations++
+			}
+			el = prev
+		}
+	}
+}
+
 // 编译时检查
 var _ Cacheable = (*MemoryMap)(nil)
@@ -1,25 +1,70 @@
 package cache
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"ripper/internal/config"
+	"time"
+)
+
 var cache Cacheable
 
-func init() {
-	cache = NewMemoryMap()
+// counters 计数器使用的缓存, 一般与 cache 相同
+// bolt 缓存的计数器只保存在内存中, 避免每次请求计数都要写盘同步, 重启后计数清零
+var counters Cacheable
 
-	// 已废弃redis缓存实现
-	/*host := os.Getenv("REDIS_HOST")
-	port := os.Getenv("REDIS_PORT")
-	psw := os.Getenv("REDIS_PASSWORD")
-	cache = NewRedisInstance(host, port, psw)*/
-}
-func Set(key string, value interface{}, ttl int) error {
-	return cache.Set(key, value, ttl)
+func init() {
+	cache = NewMemoryMap(0, 0)
+	counters = cache
 }
-func Get(key string) (interface{}, error) {
-	return cache.Get(key)
+
+// Init 按配置选择缓存实现, 默认使用内存缓存
+func Init(cfg config.CacheConfig) error {
+	switch cfg.Type {
+	case "", "memory":
+		cache = NewMemoryMap(cfg.MaxEntries, time.Duration(cfg.CleanupInterval)*time.Second)
+		counters = cache
+	case "bolt":
+		b, err := NewBoltCache(cfg.Path, time.Duration(cfg.CleanupInterval)*time.Second)
+		if err != nil {
+			return err
+		}
+		cache = b
+		counters = NewMemoryMap(0, time.Duration(cfg.CleanupInterval)*time.Second)
+	case "redis":
+		r := NewRedisInstance(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword, cfg.RedisDB)
+		if err := r.Ping(context.Background()); err != nil {
+			r.Close()
+			return fmt.Errorf("failed to connect to redis %s:%s: %v", cfg.RedisHost, cfg.RedisPort, err)
+		}
+		cache = r
+		counters = cache
+	default:
+		return fmt.Errorf("unsupported cache type: %s", cfg.Type)
+	}
+	slog.Info("using cache", "type", cfg.Type)
+	return nil
 }
-func Exist(key string) (bool, error) {
-	return cache.Exist(key)
+
+// Stats 获取内存缓存的统计信息, 其他缓存实现返回 false
+func Stats() (MemoryStats, bool) {
+	if m, ok := cache.(*MemoryMap); ok {
+		return m.Stats(), true
+	}
+	return MemoryStats{}, false
 }
-func Del(key string) error {
-	return cache.Del(key)
+
+// Close 关闭缓存, 持久化缓存需要在退出前调用
+func Close() error {
+	if counters != cache {
+		if c, ok := counters.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+	if c, ok := cache.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
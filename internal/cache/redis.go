@@ -1,9 +1,10 @@
 package cache
 
 import (
-	"fmt"
+	"context"
 	"github.com/gomodule/redigo/redis"
-	"strconv"
+	"net"
+	"strings"
 	"time"
 )
 
@@ -11,35 +12,222 @@ type Redis struct {
 	Host string
 	Port string
 	Psw  string
+	DB   int
 	Pool *redis.Pool
 }
 
-func NewRedisInstance(host string, port string, psw string) *Redis {
-	r := &Redis{Host: host, Port: port, Psw: psw}
+func NewRedisInstance(host string, port string, psw string, db int) *Redis {
+	r := &Redis{Host: host, Port: port, Psw: psw, DB: db}
 	r.init()
 	return r
 }
 
-func (r *Redis) Get(k string) (interface{}, error) {
-	return r.getConn().Do("get", k)
+// Get 获取缓存, 不存在时返回 nil
+func (r *Redis) Get(ctx context.Context, k string) ([]byte, error) {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	value, err := redis.Bytes(redis.DoContext(conn, ctx, "GET", k))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return value, err
 }
 
-func (r *Redis) Set(k string, v interface{}, ttl int) error {
-	_, err := r.getConn().Do("set", k, v, "EX", ttl)
+// Set 设置缓存中的值，并指定过期时间（秒）, 0 表示默认半小时, -1 表示永久缓存
+func (r *Redis) Set(ctx context.Context, k string, v []byte, ttl int) error {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "SET", setArgs(k, v, ttl)...)
 	return err
 }
 
-func (r *Redis) Exist(k string) (bool, error) {
-	return redis.Bool(r.getConn().Do("EXISTS", k))
+// SetNX 仅在键不存在时设置
+func (r *Redis) SetNX(ctx context.Context, k string, v []byte, ttl int) (bool, error) {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// 键已存在时返回 nil
+	reply, err := redis.DoContext(conn, ctx, "SET", append(setArgs(k, v, ttl), "NX")...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// GetDel 通过事务获取并删除键, 兼容不支持 GETDEL 命令的旧版本 redis
+func (r *Redis) GetDel(ctx context.Context, k string) ([]byte, error) {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("GET", k)
+	conn.Send("DEL", k)
+	replies, err := redis.Values(redis.DoContext(conn, ctx, "EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	if len(replies) == 0 || replies[0] == nil {
+		return nil, nil
+	}
+	return redis.Bytes(replies[0], nil)
+}
+
+// MGet 批量获取, 不存在的键为 nil
+func (r *Redis) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.ByteSlices(redis.DoContext(conn, ctx, "MGET", redis.Args{}.AddFlat(keys)...))
 }
 
-func (r *Redis) Del(k string) error {
-	_, err := r.getConn().Do("del", k)
+// MSet 通过事务批量设置, 每个键使用相同的过期时间
+func (r *Redis) MSet(ctx context.Context, values map[string][]byte, ttl int) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	for k, v := range values {
+		conn.Send("SET", setArgs(k, v, ttl)...)
+	}
+	_, err = redis.DoContext(conn, ctx, "EXEC")
 	return err
 }
 
-func (r *Redis) getConn() redis.Conn {
-	return r.Pool.Get()
+func (r *Redis) Exist(ctx context.Context, k string) (bool, error) {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return redis.Bool(redis.DoContext(conn, ctx, "EXISTS", k))
+}
+
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = redis.DoContext(conn, ctx, "DEL", redis.Args{}.AddFlat(keys)...)
+	return err
+}
+
+// incrByScript 增加计数器, 仅在键由本次调用创建时设置过期时间
+// 与 INCRBY 和 EXPIRE 分开执行不同, 脚本保证计数器不会因中途出错变为永久键, 已存在的永久键也不会被设置过期时间
+var incrByScript = redis.NewScript(1, `
+local created = redis.call("EXISTS", KEYS[1]) == 0
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return value
+`)
+
+func (r *Redis) IncrBy(ctx context.Context, k string, n int64, ttl int) (int64, error) {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return redis.Int64(incrByScript.DoContext(ctx, conn, k, n, ttl))
+}
+
+// Keys 通过 SCAN 遍历指定前缀的键, 避免 KEYS 命令阻塞 redis
+func (r *Redis) Keys(ctx context.Context, prefix string) ([]string, error) {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var keys []string
+	cursor := 0
+	pattern := redisGlobEscaper.Replace(prefix) + "*"
+	for {
+		values, err := redis.Values(redis.DoContext(conn, ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		var batch []string
+		if _, err := redis.Scan(values, &cursor, &batch); err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+// redisGlobEscaper 转义 MATCH 参数中的通配符
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// setArgs 生成 SET 命令参数, -1 表示永久缓存, 不设置过期时间
+func setArgs(k string, v []byte, ttl int) []interface{} {
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	if ttl == -1 {
+		return []interface{}{k, v}
+	}
+	return []interface{}{k, v, "EX", ttl}
+}
+
+// Ping 检查 redis 服务是否可用
+func (r *Redis) Ping(ctx context.Context) error {
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = redis.DoContext(conn, ctx, "PING")
+	return err
+}
+
+// Close 关闭连接池
+func (r *Redis) Close() error {
+	return r.Pool.Close()
+}
+
+// getConn 从连接池获取连接, 使用完毕后必须调用 Close 归还连接
+func (r *Redis) getConn(ctx context.Context) (redis.Conn, error) {
+	return r.Pool.GetContext(ctx)
 }
 
 func (r *Redis) init() {
@@ -56,6 +244,8 @@ func (r *Redis) init() {
 		// is zero, then idle connections are not closed. Applications should set
 		// the timeout to a value less than the server's timeout.
 		IdleTimeout: time.Second * 100,
+		//连接池满时等待空闲连接, 避免直接返回错误
+		Wait: true,
 		//定义拨号获得连接的函数
 		// Dial is an application supplied function for creating and configuring a
 		// connection.
@@ -63,20 +253,25 @@ func (r *Redis) init() {
 		// The connection returned from Dial must not be in a special state
 		// (subscribed to pubsub channel, transaction started, ...).
 		Dial: func() (redis.Conn, error) {
-			port, _ := strconv.Atoi(r.Port)
-			c, err := redis.Dial("tcp", fmt.Sprintf("%s:%d", r.Host, port))
-			if err != nil {
-				return nil, err
-			}
-			password := r.Psw
-			if password != "" {
-				if _, err := c.Do("AUTH", password); err != nil {
-					c.Close()
-					return nil, err
-				}
+			return redis.Dial("tcp", net.JoinHostPort(r.Host, r.Port),
+				redis.DialPassword(r.Psw),
+				redis.DialDatabase(r.DB),
+				redis.DialConnectTimeout(5*time.Second),
+				redis.DialReadTimeout(5*time.Second),
+				redis.DialWriteTimeout(5*time.Second),
+			)
+		},
+		//取出闲置超过一分钟的连接时先检查是否可用
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
 			}
-			return c, err
+			_, err := c.Do("PING")
+			return err
 		},
 	}
 
 }
+
+// 编译时检查
+var _ Cacheable = (*Redis)(nil)
@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// newTestRedis 连接本地 redis-server, 不可用时跳过测试
+// 可通过 REDIS_HOST REDIS_PORT REDIS_PASSWORD 指定地址, 测试使用 15 号库
+func newTestRedis(t *testing.T) *Redis {
+	host, port := os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if port == "" {
+		port = "6379"
+	}
+
+	r := NewRedisInstance(host, port, os.Getenv("REDIS_PASSWORD"), 15)
+	if err := r.Ping(context.Background()); err != nil {
+		r.Close()
+		t.Skipf("redis-server is not available at %s:%s: %v", host, port, err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRedisSetGet(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	key := "copilot.proxy.test.string"
+	t.Cleanup(func() { r.Del(ctx, key) })
+
+	if err := r.Set(ctx, key, []byte("abc123"), 60); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	value, err := r.Get(ctx, key)
+	if err != nil || string(value) != "abc123" {
+		t.Fatalf("Get = %q, %v, want \"abc123\"", value, err)
+	}
+
+	exist, err := r.Exist(ctx, key)
+	if err != nil || !exist {
+		t.Fatalf("Exist = %v, %v, want true", exist, err)
+	}
+
+	if err := r.Del(ctx, key); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	value, err = r.Get(ctx, key)
+	if err != nil || value != nil {
+		t.Fatalf("Get after Del = %q, %v, want nil", value, err)
+	}
+}
+
+func TestRedisPermanentKey(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	key := "copilot.proxy.test.permanent"
+	t.Cleanup(func() { r.Del(ctx, key) })
+
+	if err := r.Set(ctx, key, []byte(`{"card_code":"x"}`), -1); err != nil {
+		t.Fatalf("Set with ttl -1: %v", err)
+	}
+
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		t.Fatalf("getConn: %v", err)
+	}
+	defer conn.Close()
+	ttl, err := redis.Int(conn.Do("TTL", key))
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl != -1 {
+		t.Fatalf("TTL = %d, want -1 for a permanent key", ttl)
+	}
+}
+
+func TestRedisExpiration(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	key := "copilot.proxy.test.expire"
+	t.Cleanup(func() { r.Del(ctx, key) })
+
+	if err := r.Set(ctx, key, []byte("v"), 1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	exist, err := r.Exist(ctx, key)
+	if err != nil || exist {
+		t.Fatalf("Exist after expiration = %v, %v, want false", exist, err)
+	}
+}
+
+func TestRedisSetNXGetDel(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	key := "copilot.proxy.test.setnx"
+	t.Cleanup(func() { r.Del(ctx, key) })
+
+	ok, err := r.SetNX(ctx, key, []byte("first"), 60)
+	if err != nil || !ok {
+		t.Fatalf("SetNX on missing key = %v, %v, want true", ok, err)
+	}
+	ok, err = r.SetNX(ctx, key, []byte("second"), 60)
+	if err != nil || ok {
+		t.Fatalf("SetNX on existing key = %v, %v, want false", ok, err)
+	}
+
+	value, err := r.GetDel(ctx, key)
+	if err != nil || string(value) != "first" {
+		t.Fatalf("GetDel = %q, %v, want \"first\"", value, err)
+	}
+	value, err = r.GetDel(ctx, key)
+	if err != nil || value != nil {
+		t.Fatalf("GetDel after delete = %q, %v, want nil", value, err)
+	}
+}
+
+func TestRedisMSetMGet(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	keys := []string{"copilot.proxy.test.m1", "copilot.proxy.test.m2", "copilot.proxy.test.m3"}
+	t.Cleanup(func() { r.Del(ctx, keys...) })
+
+	err := r.MSet(ctx, map[string][]byte{keys[0]: []byte("1"), keys[1]: []byte("2")}, 60)
+	if err != nil {
+		t.Fatalf("MSet: %v", err)
+	}
+	values, err := r.MGet(ctx, keys...)
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	if len(values) != 3 || string(values[0]) != "1" || string(values[1]) != "2" || values[2] != nil {
+		t.Fatalf("MGet = %q, want [1 2 nil]", values)
+	}
+}
+
+func TestRedisTypedValue(t *testing.T) {
+	r := newTestRedis(t)
+	old := cache
+	cache = r
+	t.Cleanup(func() { cache = old })
+
+	ctx := context.Background()
+	key := "copilot.proxy.test.typed"
+	t.Cleanup(func() { Del(ctx, key) })
+
+	type authInfo struct {
+		ClientId string `json:"client_id"`
+	}
+	if err := Set(ctx, key, authInfo{ClientId: "abc"}, 60); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	value, ok, err := Get[authInfo](ctx, key)
+	if err != nil || !ok || value.ClientId != "abc" {
+		t.Fatalf("Get = %+v, %v, %v, want client_id abc", value, ok, err)
+	}
+}
+
+func TestRedisReleasesConnections(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	key := "copilot.proxy.test.pool"
+	t.Cleanup(func() { r.Del(ctx, key) })
+
+	for i := 0; i < 100; i++ {
+		if err := r.Set(ctx, key, []byte("v"), 60); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if _, err := r.Get(ctx, key); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if _, err := r.Exist(ctx, key); err != nil {
+			t.Fatalf("Exist: %v", err)
+		}
+	}
+
+	if active := r.Pool.ActiveCount(); active > 1 {
+		t.Fatalf("ActiveCount = %d, connections are not returned to the pool", active)
+	}
+}
+
+func TestRedisKeys(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	keys := []string{"copilot.proxy.test.keys.a", "copilot.proxy.test.keys.b", "copilot.proxy.test.other"}
+	t.Cleanup(func() { r.Del(ctx, keys...) })
+
+	for _, key := range keys {
+		if err := r.Set(ctx, key, []byte("v"), 60); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	found, err := r.Keys(ctx, "copilot.proxy.test.keys.")
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+	sort.Strings(found)
+	if len(found) != 2 || found[0] != keys[0] || found[1] != keys[1] {
+		t.Fatalf("Keys = %q, want %q", found, keys[:2])
+	}
+}
+
+func TestRedisIncrBy(t *testing.T) {
+	r := newTestRedis(t)
+	ctx := context.Background()
+	key := "copilot.proxy.test.counter"
+	t.Cleanup(func() { r.Del(ctx, key) })
+
+	if n, err := r.IncrBy(ctx, key, 3, 60); err != nil || n != 3 {
+		t.Fatalf("IncrBy on missing key = %d, %v, want 3", n, err)
+	}
+	if n, err := r.IncrBy(ctx, key, 2, 60); err != nil || n != 5 {
+		t.Fatalf("IncrBy on existing key = %d, %v, want 5", n, err)
+	}
+
+	conn, err := r.getConn(ctx)
+	if err != nil {
+		t.Fatalf("getConn: %v", err)
+	}
+	defer conn.Close()
+	ttl, err := redis.Int(conn.Do("TTL", key))
+	if err != nil || ttl <= 0 || ttl > 60 {
+		t.Fatalf("TTL = %d, %v, want between 1 and 60", ttl, err)
+	}
+
+	// 已存在的永久键不会被设置过期时间
+	permanent := "copilot.proxy.test.permanent_counter"
+	t.Cleanup(func() { r.Del(ctx, permanent) })
+	if err := r.Set(ctx, permanent, []byte("10"), -1); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if n, err := r.IncrBy(ctx, permanent, 1, 60); err != nil || n != 11 {
+		t.Fatalf("IncrBy on permanent key = %d, %v, want 11", n, err)
+	}
+	ttl, err = redis.Int(conn.Do("TTL", permanent))
+	if err != nil || ttl != -1 {
+		t.Fatalf("TTL of permanent key = %d, %v, want -1", ttl, err)
+	}
+}
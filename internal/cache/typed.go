@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// 泛型缓存接口, 值统一使用 json 编解码, 各缓存实现之间可以互相替换
+
+// encode 编码缓存值
+func encode[T any](key string, value T) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cache value of %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// decode 解码缓存值
+func decode[T any](key string, data []byte) (T, error) {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("failed to decode cache value of %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// Get 获取缓存, 第二个返回值表示键是否存在
+func Get[T any](ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	data, err := cache.Get(ctx, key)
+	if err != nil || data == nil {
+		return zero, false, err
+	}
+	value, err := decode[T](key, data)
+	return value, err == nil, err
+}
+
+// Set 设置缓存, ttl 单位为秒, 0 表示默认半小时, -1 表示永久缓存
+func Set[T any](ctx context.Context, key string, value T, ttl int) error {
+	data, err := encode(key, value)
+	if err != nil {
+		return err
+	}
+	return cache.Set(ctx, key, data, ttl)
+}
+
+// SetNX 仅在键不存在时设置缓存, 返回是否设置成功
+func SetNX[T any](ctx context.Context, key string, value T, ttl int) (bool, error) {
+	data, err := encode(key, value)
+	if err != nil {
+		return false, err
+	}
+	return cache.SetNX(ctx, key, data, ttl)
+}
+
+// GetDel 获取并删除缓存, 第二个返回值表示键是否存在
+func GetDel[T any](ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	data, err := cache.GetDel(ctx, key)
+	if err != nil || data == nil {
+		return zero, false, err
+	}
+	value, err := decode[T](key, data)
+	return value, err == nil, err
+}
+
+// MGet 批量获取缓存, 返回结果中只包含存在的键
+func MGet[T any](ctx context.Context, keys ...string) (map[string]T, error) {
+	data, err := cache.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(keys))
+	for i, key := range keys {
+		if data[i] == nil {
+			continue
+		}
+		value, err := decode[T](key, data[i])
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// MSet 批量设置缓存, 所有键使用相同的过期时间
+func MSet[T any](ctx context.Context, values map[string]T, ttl int) error {
+	data := make(map[string][]byte, len(values))
+	for key, value := range values {
+		encoded, err := encode(key, value)
+		if err != nil {
+			return err
+		}
+		data[key] = encoded
+	}
+	return cache.MSet(ctx, data, ttl)
+}
+
+// Exist 判断缓存是否存在
+func Exist(ctx context.Context, key string) (bool, error) {
+	return cache.Exist(ctx, key)
+}
+
+// IncrBy 增加计数器, 计数器通过 Counter 读取
+// ttl 仅在计数器不存在时生效, 已存在的键 (包括永久键) 不修改过期时间
+// 使用 bolt 缓存时计数器只保存在内存中, 不会持久化
+func IncrBy(ctx context.Context, key string, n int64, ttl int) (int64, error) {
+	return counters.IncrBy(ctx, key, n, ttl)
+}
+
+// Counter 读取计数器的值, 计数器不存在时返回 0
+func Counter(ctx context.Context, key string) (int64, error) {
+	data, err := counters.Get(ctx, key)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	return parseCounter(key, data)
+}
+
+// Keys 获取指定前缀的所有键
+func Keys(ctx context.Context, prefix string) ([]string, error) {
+	return cache.Keys(ctx, prefix)
+}
+
+// Del 删除缓存
+func Del(ctx context.Context, keys ...string) error {
+	return cache.Del(ctx, keys...)
+}
@@ -0,0 +1,356 @@
+package config
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextKey 请求上下文中保存配置的键
+const contextKey = "config"
+
+// Config 服务配置, 由配置文件和环境变量共同决定, 环境变量优先
+type Config struct {
+	Env               string `yaml:"env" env:"ENV"`
+	HTTPClientTimeout int    `yaml:"http_client_timeout" env:"HTTP_CLIENT_TIMEOUT"` // 登录等非流式请求的整体超时, 单位秒
+	ReloadInterval    int    `yaml:"reload_interval" env:"CONFIG_RELOAD_INTERVAL"`  // 配置文件变化检查间隔, 单位秒, 0 表示仅响应 SIGHUP
+
+	Server    ServerConfig    `yaml:"server"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Codex     CodexConfig     `yaml:"codex"`
+	Chat      ChatConfig      `yaml:"chat"`
+	Embedding EmbeddingConfig `yaml:"embedding"`
+	Copilot   CopilotConfig   `yaml:"copilot"`
+	Upstream  UpstreamConfig  `yaml:"upstream"`
+	Cache     CacheConfig     `yaml:"cache"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Usage     UsageConfig     `yaml:"usage"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Log       LogConfig       `yaml:"log"`
+	Capture   CaptureConfig   `yaml:"capture"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+}
+
+// ServerConfig 服务监听及对外地址配置
+type ServerConfig struct {
+	Host             string `yaml:"host" env:"HOST"`
+	Port             int    `yaml:"port" env:"PORT"`
+	HTTPSPort        int    `yaml:"https_port" env:"HTTPS_PORT"`
+	DefaultBaseURL   string `yaml:"default_base_url" env:"DEFAULT_BASE_URL"`
+	APIBaseURL       string `yaml:"api_base_url" env:"API_BASE_URL"`
+	ProxyBaseURL     string `yaml:"proxy_base_url" env:"PROXY_BASE_URL"`
+	TelemetryBaseURL string `yaml:"telemetry_base_url" env:"TELEMETRY_BASE_URL"`
+}
+
+// AuthConfig 登录鉴权配置
+type AuthConfig struct {
+	TokenSalt             string `yaml:"token_salt" env:"TOKEN_SALT"`
+	LoginPassword         string `yaml:"login_password" env:"LOGIN_PASSWORD"`
+	LoginTokenExpiresIn   int    `yaml:"login_token_expires_in" env:"LOGIN_TOKEN_EXPIRES_IN"` // 插件登录令牌有效期, 单位天, 0 表示长期有效
+	VSCopilotClientID     string `yaml:"vs_copilot_client_id" env:"VS_COPILOT_CLIENT_ID"`
+	VSCopilotClientSecret string `yaml:"vs_copilot_client_secret" env:"VS_COPILOT_CLIENT_SECRET"`
+	AdminToken            string `yaml:"admin_token" env:"ADMIN_TOKEN"`
+	UserAuth              bool   `yaml:"user_auth" env:"USER_AUTH"` // 用户模式, 登录和请求需要使用管理员分配的访问令牌
+	UserDBPath            string `yaml:"user_db_path" env:"USER_DB_PATH"`
+}
+
+// CodexConfig 代码补全服务配置
+type CodexConfig struct {
+	APIBase          string  `yaml:"api_base" env:"CODEX_API_BASE"`
+	APIKeys          List    `yaml:"api_keys" env:"CODEX_API_KEY"`
+	ModelName        string  `yaml:"model_name" env:"CODEX_API_MODEL_NAME"`
+	MaxTokens        int     `yaml:"max_tokens" env:"CODEX_MAX_TOKENS"`
+	Temperature      float64 `yaml:"temperature" env:"CODEX_TEMPERATURE"` // -1 表示跟随插件设置
+	ServiceType      string  `yaml:"service_type" env:"CODEX_SERVICE_TYPE"`
+	LimitPrompt      int     `yaml:"limit_prompt" env:"CODEX_LIMIT_PROMPT"`
+	Debounce         int     `yaml:"debounce" env:"COPILOT_DEBOUNCE"` // 补全防抖时间, 单位毫秒
+	FIMTemplatesFile string  `yaml:"fim_templates_file" env:"FIM_TEMPLATES_FILE"`
+}
+
+// ChatConfig 对话服务配置
+type ChatConfig struct {
+	APIBase          string `yaml:"api_base" env:"CHAT_API_BASE"`
+	APIKeys          List   `yaml:"api_keys" env:"CHAT_API_KEY"`
+	ModelName        string `yaml:"model_name" env:"CHAT_API_MODEL_NAME"`
+	ServiceType      string `yaml:"service_type" env:"CHAT_SERVICE_TYPE"`
+	MaxTokens        int    `yaml:"max_tokens" env:"CHAT_MAX_TOKENS"`
+	Locale           string `yaml:"locale" env:"CHAT_LOCALE"`
+	UseTools         bool   `yaml:"use_tools" env:"CHAT_USE_TOOLS"`
+	LightweightModel string `yaml:"lightweight_model" env:"LIGHTWEIGHT_MODEL"`
+	ModelsFile       string `yaml:"models_file" env:"MODELS_FILE"`
+}
+
+// EmbeddingConfig Embedding 服务配置
+type EmbeddingConfig struct {
+	APIBase       string `yaml:"api_base" env:"EMBEDDING_API_BASE"`
+	APIKeys       List   `yaml:"api_keys" env:"EMBEDDING_API_KEY"`
+	ModelName     string `yaml:"model_name" env:"EMBEDDING_API_MODEL_NAME"`
+	DimensionSize int    `yaml:"dimension_size" env:"EMBEDDING_DIMENSION_SIZE"`
+	ServiceType   string `yaml:"service_type" env:"EMBEDDING_SERVICE_TYPE"`
+}
+
+// CopilotConfig 官方 Copilot 服务相关配置
+type CopilotConfig struct {
+	ClientType             string `yaml:"client_type" env:"COPILOT_CLIENT_TYPE"`
+	GHUTokens              List   `yaml:"ghu_tokens" env:"COPILOT_GHU_TOKEN"`
+	ProxyAll               bool   `yaml:"proxy_all" env:"COPILOT_PROXY_ALL"`
+	AccountType            string `yaml:"account_type" env:"COPILOT_ACCOUNT_TYPE"`
+	DisguiseTokenExpiresAt int    `yaml:"disguise_token_expires_at" env:"DISGUISE_COPILOT_TOKEN_EXPIRES_AT"`
+}
+
+// UpstreamConfig 上游路由、熔断及 key 池配置
+type UpstreamConfig struct {
+	ProvidersFile           string `yaml:"providers_file" env:"PROVIDERS_FILE"`
+	CircuitBreakerThreshold int    `yaml:"circuit_breaker_threshold" env:"CIRCUIT_BREAKER_THRESHOLD"`
+	CircuitBreakerCooldown  int    `yaml:"circuit_breaker_cooldown" env:"CIRCUIT_BREAKER_COOLDOWN"`
+	KeyPoolStrategy         string `yaml:"key_pool_strategy" env:"KEY_POOL_STRATEGY"`
+	KeyPoolBackoff          int    `yaml:"key_pool_backoff" env:"KEY_POOL_BACKOFF"`
+	AzureAPIVersion         string `yaml:"azure_api_version" env:"AZURE_API_VERSION"`
+
+	// 上游 http 客户端配置, 超时单位为秒, 0 表示不限制
+	ConnectTimeout      int    `yaml:"connect_timeout" env:"UPSTREAM_CONNECT_TIMEOUT"`
+	TTFBTimeout         int    `yaml:"ttfb_timeout" env:"UPSTREAM_TTFB_TIMEOUT"`
+	IdleTimeout         int    `yaml:"idle_timeout" env:"UPSTREAM_IDLE_TIMEOUT"`
+	HTTP2               bool   `yaml:"http2" env:"UPSTREAM_HTTP2"`
+	CAFile              string `yaml:"ca_file" env:"UPSTREAM_CA_FILE"`
+	InsecureSkipVerify  bool   `yaml:"insecure_skip_verify" env:"UPSTREAM_INSECURE_SKIP_VERIFY"`
+	MaxIdleConnsPerHost int    `yaml:"max_idle_conns_per_host" env:"UPSTREAM_MAX_IDLE_CONNS_PER_HOST"`
+}
+
+// CacheConfig 缓存配置, 修改后需要重启才能生效
+type CacheConfig struct {
+	Type            string `yaml:"type" env:"CACHE_TYPE"`                         // memory、bolt 或 redis
+	Path            string `yaml:"path" env:"CACHE_PATH"`                         // bolt 数据文件路径
+	MaxEntries      int    `yaml:"max_entries" env:"CACHE_MAX_ENTRIES"`           // 内存缓存最大键数量, 0 表示不限制
+	CleanupInterval int    `yaml:"cleanup_interval" env:"CACHE_CLEANUP_INTERVAL"` // 内存及 bolt 缓存清理过期键的间隔, 单位秒
+	RedisHost       string `yaml:"redis_host" env:"REDIS_HOST"`
+	RedisPort       string `yaml:"redis_port" env:"REDIS_PORT"`
+	RedisPassword   string `yaml:"redis_password" env:"REDIS_PASSWORD"`
+	RedisDB         int    `yaml:"redis_db" env:"REDIS_DB"`
+}
+
+// RateLimitConfig 按用户限流配置, 0 表示不限制
+// RPM 为每分钟请求数, TPD 为每天 token 数
+type RateLimitConfig struct {
+	CompletionsRPM int `yaml:"completions_rpm" env:"RATE_LIMIT_COMPLETIONS_RPM"`
+	CompletionsTPD int `yaml:"completions_tpd" env:"RATE_LIMIT_COMPLETIONS_TPD"`
+	ChatRPM        int `yaml:"chat_rpm" env:"RATE_LIMIT_CHAT_RPM"`
+	ChatTPD        int `yaml:"chat_tpd" env:"RATE_LIMIT_CHAT_TPD"`
+	EmbeddingsRPM  int `yaml:"embeddings_rpm" env:"RATE_LIMIT_EMBEDDINGS_RPM"`
+	EmbeddingsTPD  int `yaml:"embeddings_tpd" env:"RATE_LIMIT_EMBEDDINGS_TPD"`
+}
+
+// Limits 获取请求类别的每分钟请求数和每天 token 数限制, category 为 completions、chat 或 embeddings
+func (c RateLimitConfig) Limits(category string) (rpm int, tpd int) {
+	switch category {
+	case "completions":
+		return c.CompletionsRPM, c.CompletionsTPD
+	case "chat":
+		return c.ChatRPM, c.ChatTPD
+	case "embeddings":
+		return c.EmbeddingsRPM, c.EmbeddingsTPD
+	}
+	return 0, 0
+}
+
+// UsageConfig token 用量统计配置, 修改后需要重启才能生效
+type UsageConfig struct {
+	Enabled bool   `yaml:"enabled" env:"USAGE_ENABLED"`
+	Path    string `yaml:"path" env:"USAGE_DB_PATH"`
+}
+
+// MetricsConfig Prometheus 监控指标配置, 修改后需要重启才能生效
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled" env:"METRICS_ENABLED"`
+	Port    int  `yaml:"port" env:"METRICS_PORT"` // 独立的指标端口, 0 表示使用服务端口并通过 ADMIN_TOKEN 鉴权
+}
+
+// LogConfig 日志配置, 除日志级别外修改后需要重启才能生效
+type LogConfig struct {
+	Level   string `yaml:"level" env:"LOG_LEVEL"`       // debug、info、warn 或 error
+	Format  string `yaml:"format" env:"LOG_FORMAT"`     // json 或 text
+	Dir     string `yaml:"dir" env:"LOG_DIR"`           // 为空时只输出到控制台
+	MaxSize int    `yaml:"max_size" env:"LOG_MAX_SIZE"` // 单个日志文件最大大小, 单位 MB
+	MaxAge  int    `yaml:"max_age" env:"LOG_MAX_AGE"`   // 日志文件保留天数
+}
+
+// CaptureConfig 请求捕获配置, 用于记录请求和响应以便重放复现问题, 支持热加载
+type CaptureConfig struct {
+	Enabled bool   `yaml:"enabled" env:"CAPTURE_ENABLED"`
+	Dir     string `yaml:"dir" env:"CAPTURE_DIR"`
+	Users   List   `yaml:"users" env:"CAPTURE_USERS"` // 只捕获这些用户的请求, 如 user:1、tid:xxx, 为空时捕获全部用户
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置, 修改后需要重启才能生效
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled" env:"TRACING_ENABLED"`
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER"`         // otlp 或 file
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`         // OTLP/HTTP 接收地址
+	File        string  `yaml:"file" env:"TRACING_FILE"`                 // file 导出的文件路径
+	ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME"` // 上报的 service.name
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"` // 采样率, 0 到 1
+}
+
+// Default 默认配置, 与 PARAM.md 中的默认值保持一致
+func Default() *Config {
+	return &Config{
+		Env:               "production",
+		HTTPClientTimeout: 60,
+		ReloadInterval:    5,
+		Server: ServerConfig{
+			Host:             "0.0.0.0",
+			Port:             1188,
+			HTTPSPort:        443,
+			DefaultBaseURL:   "https://mycopilot.com",
+			APIBaseURL:       "https://api.mycopilot.com",
+			ProxyBaseURL:     "https://copilot-proxy.mycopilot.com",
+			TelemetryBaseURL: "https://copilot-telemetry-service.mycopilot.com",
+		},
+		Auth: AuthConfig{
+			TokenSalt:         "7L3Gqrn24TUWzLwG",
+			VSCopilotClientID: "a200baed193bb2088a6e",
+			UserDBPath:        "data/users.db",
+		},
+		Codex: CodexConfig{
+			APIBase:          "https://api.deepseek.com/beta/v1/completions",
+			MaxTokens:        500,
+			ServiceType:      "default",
+			Debounce:         200,
+			FIMTemplatesFile: "fim_templates.json",
+		},
+		Chat: ChatConfig{
+			APIBase:          "https://api.deepseek.com/v1/chat/completions",
+			ModelName:        "deepseek-chat",
+			ServiceType:      "default",
+			MaxTokens:        4096,
+			Locale:           "zh_CN",
+			UseTools:         true,
+			LightweightModel: "gpt-4o-mini",
+			ModelsFile:       "models.json",
+		},
+		Embedding: EmbeddingConfig{
+			ModelName:     "m3e",
+			DimensionSize: 1536,
+			ServiceType:   "default",
+		},
+		Copilot: CopilotConfig{
+			ClientType:             "default",
+			AccountType:            "individual",
+			DisguiseTokenExpiresAt: 1800,
+		},
+		Upstream: UpstreamConfig{
+			ProvidersFile:           "providers.json",
+			CircuitBreakerThreshold: 3,
+			CircuitBreakerCooldown:  30,
+			KeyPoolStrategy:         "round-robin",
+			KeyPoolBackoff:          60,
+			AzureAPIVersion:         "2024-10-21",
+			ConnectTimeout:          10,
+			TTFBTimeout:             60,
+			IdleTimeout:             60,
+			HTTP2:                   true,
+			MaxIdleConnsPerHost:     32,
+		},
+		Cache: CacheConfig{
+			Type:            "memory",
+			Path:            "data/cache.db",
+			MaxEntries:      10000,
+			CleanupInterval: 60,
+			RedisHost:       "127.0.0.1",
+			RedisPort:       "6379",
+		},
+		Usage: UsageConfig{
+			Path: "data/usage.db",
+		},
+		Log: LogConfig{
+			Level:   "info",
+			Format:  "json",
+			Dir:     "logs",
+			MaxSize: 100,
+			MaxAge:  7,
+		},
+		Capture: CaptureConfig{
+			Dir: "data/captures",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "otlp",
+			Endpoint:    "http://127.0.0.1:4318/v1/traces",
+			File:        "data/traces.jsonl",
+			ServiceName: "ripper",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// HTTPTimeout 登录等非流式请求的整体超时
+func (c *Config) HTTPTimeout() time.Duration {
+	return time.Duration(c.HTTPClientTimeout) * time.Second
+}
+
+// Secrets 配置中的密钥, 用于日志脱敏, key#权重 格式的 key 只返回 key 部分
+func (c *Config) Secrets() []string {
+	list := []string{
+		c.Auth.TokenSalt, c.Auth.LoginPassword, c.Auth.VSCopilotClientSecret, c.Auth.AdminToken, c.Cache.RedisPassword,
+	}
+	for _, keys := range []List{c.Codex.APIKeys, c.Chat.APIKeys, c.Embedding.APIKeys, c.Copilot.GHUTokens} {
+		for _, key := range keys {
+			list = append(list, StripKeyWeight(key))
+		}
+	}
+	return list
+}
+
+// StripKeyWeight 去掉 key#权重 格式中的权重
+func StripKeyWeight(key string) string {
+	if i := strings.LastIndex(key, "#"); i > 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// IsGithub 是否使用官方 Copilot 服务
+func (c *Config) IsGithub() bool {
+	return c.Copilot.ClientType == "github"
+}
+
+// ProxyAll 是否将补全和对话请求全部代理到官方 Copilot 服务
+func (c *Config) ProxyAll() bool {
+	return c.IsGithub() && c.Copilot.ProxyAll
+}
+
+var current atomic.Pointer[Config]
+
+func init() {
+	current.Store(Default())
+}
+
+// Current 获取当前生效的全局配置
+func Current() *Config {
+	return current.Load()
+}
+
+// Set 替换全局配置
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+// Inject 将请求开始时的全局配置注入请求上下文, 处理函数通过 FromContext 获取
+// 配置热加载后, 已开始处理的请求 (如流式响应) 仍使用旧的配置
+func Inject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKey, Current())
+		c.Next()
+	}
+}
+
+// FromContext 获取请求上下文中的配置, 未注入时返回全局配置
+func FromContext(c *gin.Context) *Config {
+	if v, ok := c.Get(contextKey); ok {
+		if cfg, ok := v.(*Config); ok {
+			return cfg
+		}
+	}
+	return Current()
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// List 列表配置, 配置文件中可使用数组或英文逗号分隔的字符串, 环境变量中使用英文逗号分隔
+type List []string
+
+// UnmarshalYAML 兼容数组和英文逗号分隔的字符串
+func (l *List) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = splitList(node.Value)
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*l = splitList(strings.Join(values, ","))
+	return nil
+}
+
+// String 以英文逗号拼接
+func (l List) String() string {
+	return strings.Join(l, ",")
+}
+
+// splitList 拆分英文逗号分隔的字符串, 忽略空项
+func splitList(s string) List {
+	list := make(List, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Load 加载配置: 默认值 < 配置文件 < 环境变量, 加载后校验
+// path 为空或文件不存在时仅使用默认值和环境变量
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+		}
+		if err == nil {
+			if err := yaml.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+			}
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+var listType = reflect.TypeOf(List{})
+
+// applyEnv 使用环境变量覆盖带 env 标签的字段, 空值视为未设置
+func applyEnv(v reflect.Value) error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		name := t.Field(i).Tag.Get("env")
+		value := strings.TrimSpace(os.Getenv(name))
+		if name == "" || value == "" {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for %s: %q %v", name, value, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setField 按字段类型解析字符串
+func setField(field reflect.Value, value string) error {
+	if field.Type() == listType {
+		field.Set(reflect.ValueOf(splitList(value)))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("is not an integer")
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("is not a number")
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("is not a boolean")
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("has unsupported type %s", field.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Validate 校验配置, 返回所有不合法的配置项
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.positive("HTTP_CLIENT_TIMEOUT", c.HTTPClientTimeout)
+	v.notNegative("CONFIG_RELOAD_INTERVAL", c.ReloadInterval)
+
+	v.port("PORT", c.Server.Port)
+	v.port("HTTPS_PORT", c.Server.HTTPSPort)
+	v.url("DEFAULT_BASE_URL", c.Server.DefaultBaseURL, true)
+	v.url("API_BASE_URL", c.Server.APIBaseURL, true)
+	v.url("PROXY_BASE_URL", c.Server.ProxyBaseURL, true)
+	v.url("TELEMETRY_BASE_URL", c.Server.TelemetryBaseURL, true)
+
+	v.required("TOKEN_SALT", c.Auth.TokenSalt)
+	v.required("USER_DB_PATH", c.Auth.UserDBPath)
+	v.notNegative("LOGIN_TOKEN_EXPIRES_IN", c.Auth.LoginTokenExpiresIn)
+
+	v.oneOf("COPILOT_CLIENT_TYPE", c.Copilot.ClientType, "default", "github")
+	v.oneOf("COPILOT_ACCOUNT_TYPE", c.Copilot.AccountType, "individual", "business")
+	v.positive("DISGUISE_COPILOT_TOKEN_EXPIRES_AT", c.Copilot.DisguiseTokenExpiresAt)
+	if c.IsGithub() && len(c.Copilot.GHUTokens) == 0 {
+		v.add("COPILOT_GHU_TOKEN is required when COPILOT_CLIENT_TYPE is github")
+	}
+
+	// 未全代理官方服务时, 补全和对话由配置的上游处理
+	// 存在多模型路由配置文件时, 上游地址由路由配置校验
+	_, err := os.Stat(c.Upstream.ProvidersFile)
+	needUpstream := !c.ProxyAll() && (c.Upstream.ProvidersFile == "" || err != nil)
+	v.url("CODEX_API_BASE", c.Codex.APIBase, needUpstream)
+	v.url("CHAT_API_BASE", c.Chat.APIBase, needUpstream)
+
+	v.positive("CODEX_MAX_TOKENS", c.Codex.MaxTokens)
+	if c.Codex.Temperature != -1 && (c.Codex.Temperature < 0 || c.Codex.Temperature > 2) {
+		v.add("CODEX_TEMPERATURE must be -1 or between 0 and 2, got %v", c.Codex.Temperature)
+	}
+	v.oneOf("CODEX_SERVICE_TYPE", c.Codex.ServiceType, "default", "ollama", "azure")
+	v.notNegative("CODEX_LIMIT_PROMPT", c.Codex.LimitPrompt)
+	v.notNegative("COPILOT_DEBOUNCE", c.Codex.Debounce)
+
+	v.positive("CHAT_MAX_TOKENS", c.Chat.MaxTokens)
+	v.oneOf("CHAT_SERVICE_TYPE", c.Chat.ServiceType, "default", "openai", "anthropic", "gemini", "ollama", "responses", "azure")
+
+	v.url("EMBEDDING_API_BASE", c.Embedding.APIBase, false)
+	v.positive("EMBEDDING_DIMENSION_SIZE", c.Embedding.DimensionSize)
+	v.oneOf("EMBEDDING_SERVICE_TYPE", c.Embedding.ServiceType, "default", "azure")
+	if c.Embedding.APIBase != "" && c.Embedding.ModelName == "" {
+		v.add("EMBEDDING_API_MODEL_NAME is required when EMBEDDING_API_BASE is set")
+	}
+
+	v.positive("CIRCUIT_BREAKER_THRESHOLD", c.Upstream.CircuitBreakerThreshold)
+	v.positive("CIRCUIT_BREAKER_COOLDOWN", c.Upstream.CircuitBreakerCooldown)
+	v.oneOf("KEY_POOL_STRATEGY", c.Upstream.KeyPoolStrategy, "round-robin", "weighted", "least-used")
+	v.positive("KEY_POOL_BACKOFF", c.Upstream.KeyPoolBackoff)
+	v.positive("UPSTREAM_CONNECT_TIMEOUT", c.Upstream.ConnectTimeout)
+	v.notNegative("UPSTREAM_TTFB_TIMEOUT", c.Upstream.TTFBTimeout)
+	v.notNegative("UPSTREAM_IDLE_TIMEOUT", c.Upstream.IdleTimeout)
+	v.notNegative("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", c.Upstream.MaxIdleConnsPerHost)
+
+	v.oneOf("CACHE_TYPE", c.Cache.Type, "memory", "bolt", "redis")
+	switch c.Cache.Type {
+	case "memory":
+		v.notNegative("CACHE_MAX_ENTRIES", c.Cache.MaxEntries)
+		v.notNegative("CACHE_CLEANUP_INTERVAL", c.Cache.CleanupInterval)
+	case "bolt":
+		v.required("CACHE_PATH", c.Cache.Path)
+		v.notNegative("CACHE_CLEANUP_INTERVAL", c.Cache.CleanupInterval)
+	case "redis":
+		v.required("REDIS_HOST", c.Cache.RedisHost)
+		v.required("REDIS_PORT", c.Cache.RedisPort)
+		v.notNegative("REDIS_DB", c.Cache.RedisDB)
+	}
+
+	v.notNegative("RATE_LIMIT_COMPLETIONS_RPM", c.RateLimit.CompletionsRPM)
+	v.notNegative("RATE_LIMIT_COMPLETIONS_TPD", c.RateLimit.CompletionsTPD)
+	v.notNegative("RATE_LIMIT_CHAT_RPM", c.RateLimit.ChatRPM)
+	v.notNegative("RATE_LIMIT_CHAT_TPD", c.RateLimit.ChatTPD)
+	v.notNegative("RATE_LIMIT_EMBEDDINGS_RPM", c.RateLimit.EmbeddingsRPM)
+	v.notNegative("RATE_LIMIT_EMBEDDINGS_TPD", c.RateLimit.EmbeddingsTPD)
+
+	if c.Usage.Enabled {
+		v.required("USAGE_DB_PATH", c.Usage.Path)
+	}
+
+	if c.Metrics.Enabled && c.Metrics.Port != 0 {
+		v.port("METRICS_PORT", c.Metrics.Port)
+	}
+
+	v.oneOf("LOG_LEVEL", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+	v.oneOf("LOG_FORMAT", c.Log.Format, "json", "text")
+	v.notNegative("LOG_MAX_SIZE", c.Log.MaxSize)
+	v.notNegative("LOG_MAX_AGE", c.Log.MaxAge)
+
+	if c.Capture.Enabled {
+		v.required("CAPTURE_DIR", c.Capture.Dir)
+	}
+
+	if c.Tracing.Enabled {
+		v.oneOf("TRACING_EXPORTER", c.Tracing.Exporter, "otlp", "file")
+		switch c.Tracing.Exporter {
+		case "otlp":
+			v.url("TRACING_ENDPOINT", c.Tracing.Endpoint, true)
+		case "file":
+			v.required("TRACING_FILE", c.Tracing.File)
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			v.add("TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+	}
+	return nil
+}
+
+// validator 收集校验错误
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("  - "+format, args...))
+}
+
+func (v *validator) required(name string, value string) {
+	if value == "" {
+		v.add("%s is required", name)
+	}
+}
+
+func (v *validator) positive(name string, value int) {
+	if value <= 0 {
+		v.add("%s must be greater than 0, got %d", name, value)
+	}
+}
+
+func (v *validator) notNegative(name string, value int) {
+	if value < 0 {
+		v.add("%s must not be negative, got %d", name, value)
+	}
+}
+
+func (v *validator) port(name string, value int) {
+	if value <= 0 || value > 65535 {
+		v.add("%s must be a valid port between 1 and 65535, got %d", name, value)
+	}
+}
+
+func (v *validator) oneOf(name string, value string, options ...string) {
+	for _, option := range options {
+		if value == option {
+			return
+		}
+	}
+	v.add("%s must be one of %v, got %q", name, options, value)
+}
+
+// url 校验地址是否为完整的 http(s) 地址, required 为 false 时允许为空
+func (v *validator) url(name string, value string, required bool) {
+	if value == "" {
+		if required {
+			v.add("%s is required", name)
+		}
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("%s must be a valid http(s) url, got %q", name, value)
+	}
+}
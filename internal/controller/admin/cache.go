@@ -0,0 +1,17 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"ripper/internal/cache"
+	"ripper/internal/config"
+	"ripper/internal/response"
+)
+
+// getCacheStats 获取缓存类型及内存缓存的命中、淘汰等统计信息
+func getCacheStats(ctx *gin.Context) {
+	result := gin.H{"type": config.FromContext(ctx).Cache.Type}
+	if stats, ok := cache.Stats(); ok {
+		result["stats"] = stats
+	}
+	response.SuccessJson(ctx, "ok", result)
+}
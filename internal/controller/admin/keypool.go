@@ -0,0 +1,12 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"ripper/internal/app/keypool"
+	"ripper/internal/response"
+)
+
+// getKeyPools 获取所有 key 池中每个 key 的健康状态
+func getKeyPools(ctx *gin.Context) {
+	response.SuccessJson(ctx, "ok", keypool.Snapshot())
+}
@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"ripper/internal/middleware"
+)
+
+// GinApi 注册管理接口路由
+func GinApi(g *gin.RouterGroup) {
+	adminGroup := g.Group("/admin")
+	adminGroup.Use(middleware.AdminCheckAuth())
+	{
+		adminGroup.GET("/keypools", getKeyPools)
+		adminGroup.GET("/cache", getCacheStats)
+
+		// 用户及访问令牌管理, 需要启用 USER_AUTH
+		adminGroup.GET("/users", getUsers)
+		adminGroup.POST("/users", postUser)
+		adminGroup.PATCH("/users/:id", patchUser)
+		adminGroup.DELETE("/users/:id", deleteUser)
+		adminGroup.GET("/users/:id/tokens", getUserTokens)
+		adminGroup.POST("/users/:id/tokens", postUserToken)
+		adminGroup.DELETE("/users/:id/tokens/:token_id", deleteUserToken)
+
+		// 登录会话管理
+		adminGroup.GET("/sessions", getSessions)
+		adminGroup.DELETE("/sessions", deleteSessions)
+		adminGroup.DELETE("/sessions/:id", deleteSession)
+
+		// token 用量统计, 需要启用 USAGE_ENABLED
+		adminGroup.GET("/usage", getUsage)
+	}
+}
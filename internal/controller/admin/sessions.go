@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"ripper/internal/app/session"
+	"ripper/internal/response"
+)
+
+// getSessions 获取未过期的登录会话, 可通过 user_id 和 client_id 参数过滤
+func getSessions(ctx *gin.Context) {
+	list, err := session.List(ctx.Query("user_id"), ctx.Query("client_id"))
+	if err != nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 500,
+			Msg:  err.Error(),
+		}, false)
+		return
+	}
+	response.SuccessJson(ctx, "ok", list)
+}
+
+// deleteSession 撤销登录会话, 会话记录已被清理时同样可以撤销
+func deleteSession(ctx *gin.Context) {
+	if err := session.Revoke(ctx.Param("id")); err != nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 500,
+			Msg:  err.Error(),
+		}, false)
+		return
+	}
+	response.SuccessJson(ctx, "ok")
+}
+
+// deleteSessions 撤销所有匹配 user_id 和 client_id 参数的登录会话, 两个参数都为空时撤销全部会话
+func deleteSessions(ctx *gin.Context) {
+	count, err := session.RevokeAll(ctx.Query("user_id"), ctx.Query("client_id"))
+	if err != nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 500,
+			Msg:  err.Error(),
+		}, false)
+		return
+	}
+	response.SuccessJson(ctx, "ok", gin.H{"revoked": count})
+}
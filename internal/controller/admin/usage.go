@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"encoding/csv"
+	"github.com/gin-gonic/gin"
+	"ripper/internal/app/usage"
+	"ripper/internal/response"
+	"strconv"
+	"time"
+)
+
+// getUsage 查询 token 用量, 支持 from to user model endpoint 参数过滤, format=csv 时导出 CSV 文件
+func getUsage(ctx *gin.Context) {
+	store := usage.Default()
+	if store == nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 403,
+			Msg:  "usage accounting is not enabled, set USAGE_ENABLED=true",
+		}, false)
+		return
+	}
+
+	filter := usage.Filter{
+		From:     ctx.Query("from"),
+		To:       ctx.Query("to"),
+		User:     ctx.Query("user"),
+		Model:    ctx.Query("model"),
+		Endpoint: ctx.Query("endpoint"),
+	}
+	for _, date := range []string{filter.From, filter.To} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			response.FailJson(ctx, response.FailStruct{
+				Code: 422,
+				Msg:  "from and to must be dates like 2006-01-02",
+			}, false)
+			return
+		}
+	}
+
+	list, err := store.Query(filter)
+	if err != nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 500,
+			Msg:  err.Error(),
+		}, false)
+		return
+	}
+
+	if ctx.Query("format") != "csv" {
+		response.SuccessJson(ctx, "ok", list)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="usage.csv"`)
+	w := csv.NewWriter(ctx.Writer)
+	_ = w.Write([]string{"date", "user", "model", "endpoint", "requests", "estimated_requests", "prompt_tokens", "completion_tokens", "total_tokens"})
+	for _, r := range list {
+		_ = w.Write([]string{
+			r.Date, r.User, r.Model, r.Endpoint,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.EstimatedCount, 10),
+			strconv.FormatInt(r.PromptTokens, 10),
+			strconv.FormatInt(r.CompletionTokens, 10),
+			strconv.FormatInt(r.TotalTokens, 10),
+		})
+	}
+	w.Flush()
+}
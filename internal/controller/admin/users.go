@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"ripper/internal/app/users"
+	"ripper/internal/response"
+	"time"
+)
+
+type createUserRequest struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type updateUserRequest struct {
+	DisplayName *string `json:"display_name"`
+	Disabled    *bool   `json:"disabled"`
+}
+
+type createTokenRequest struct {
+	Name      string `json:"name"`
+	ExpiresIn int    `json:"expires_in"` // 有效天数, 0 表示永不过期
+}
+
+// getUsers 获取所有用户
+func getUsers(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	list, err := store.ListUsers()
+	if err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok", list)
+}
+
+// postUser 创建用户
+func postUser(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	var req createUserRequest
+	if err := response.BindStruct(ctx, &req); err != nil {
+		return
+	}
+	user, err := store.CreateUser(req.Name, req.DisplayName)
+	if err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok", user)
+}
+
+// patchUser 修改用户的显示名称或禁用状态, 禁用后该用户的所有令牌立即失效
+func patchUser(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	var req updateUserRequest
+	if err := response.BindStruct(ctx, &req); err != nil {
+		return
+	}
+	user, err := store.UpdateUser(ctx.Param("id"), req.DisplayName, req.Disabled)
+	if err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok", user)
+}
+
+// deleteUser 删除用户及其所有访问令牌
+func deleteUser(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	if err := store.DeleteUser(ctx.Param("id")); err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok")
+}
+
+// getUserTokens 获取用户的访问令牌, 不包含明文
+func getUserTokens(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	list, err := store.ListTokens(ctx.Param("id"))
+	if err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok", list)
+}
+
+// postUserToken 为用户创建访问令牌, 明文令牌只在此时返回一次
+func postUserToken(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	var req createTokenRequest
+	if err := response.BindStruct(ctx, &req); err != nil {
+		return
+	}
+	if req.ExpiresIn < 0 {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 422,
+			Msg:  "expires_in must not be negative",
+		}, false)
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresIn)
+		expiresAt = &t
+	}
+	plain, token, err := store.CreateToken(ctx.Param("id"), req.Name, expiresAt)
+	if err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok", gin.H{
+		"token": plain,
+		"info":  token,
+	})
+}
+
+// deleteUserToken 撤销用户的访问令牌
+func deleteUserToken(ctx *gin.Context) {
+	store := userStore(ctx)
+	if store == nil {
+		return
+	}
+	if err := store.RevokeToken(ctx.Param("id"), ctx.Param("token_id")); err != nil {
+		failUserStore(ctx, err)
+		return
+	}
+	response.SuccessJson(ctx, "ok")
+}
+
+// userStore 获取用户存储, 未启用用户模式时返回错误并返回 nil
+func userStore(ctx *gin.Context) *users.Store {
+	store := users.Default()
+	if store == nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 403,
+			Msg:  "user auth is not enabled, set USER_AUTH=true",
+		}, false)
+	}
+	return store
+}
+
+// failUserStore 将用户存储的错误转换为响应
+func failUserStore(ctx *gin.Context, err error) {
+	code := 500
+	switch {
+	case errors.Is(err, users.ErrNotFound), errors.Is(err, users.ErrTokenNotOwned):
+		code = 404
+	case errors.Is(err, users.ErrNameExists), errors.Is(err, users.ErrNameRequired):
+		code = 422
+	}
+	response.FailJson(ctx, response.FailStruct{
+		Code: code,
+		Msg:  err.Error(),
+	}, false)
+}
@@ -2,11 +2,15 @@ package auth
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"log/slog"
 	"net/http"
-	"os"
 	"ripper/internal/app/github_auth"
+	"ripper/internal/app/session"
+	"ripper/internal/app/users"
+	"ripper/internal/config"
 	"ripper/internal/middleware"
 	"ripper/internal/response"
 	jwtpkg "ripper/pkg/jwt"
@@ -50,7 +54,7 @@ func postLoginDeviceCode(ctx *gin.Context) {
 		return
 	}
 
-	uid, devid, err := github_auth.BindClientToCode(cli.ClientId, 1800)
+	uid, devid, err := github_auth.BindClientToCode(ctx, cli.ClientId, 1800)
 	if err != nil {
 		response.FailJson(ctx, response.FailStruct{
 			Code: -1,
@@ -61,7 +65,7 @@ func postLoginDeviceCode(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, postLoginDeviceCodeResponse{
 		DeviceCode:      devid,
 		UserCode:        uid,
-		VerificationUrl: fmt.Sprintf("%s/login/device?user_code=%s", os.Getenv("DEFAULT_BASE_URL"), uid),
+		VerificationUrl: fmt.Sprintf("%s/login/device?user_code=%s", config.FromContext(ctx).Server.DefaultBaseURL, uid),
 		ExpiresIn:       1800,
 		Interval:        5,
 	})
@@ -78,9 +82,7 @@ func postLoginOauthAccessToken(ctx *gin.Context) {
 		return
 	}
 	cliAuthInfo := v.(*github_auth.ClientAuthInfo)
-	t := time.Now()
-	t.Add(24 * 3 * time.Hour)
-	u, err := github_auth.GetClientAuthInfo(cliAuthInfo.UserCode)
+	u, err := github_auth.GetClientAuthInfo(ctx, cliAuthInfo.UserCode)
 	if err != nil {
 		ctx.JSON(http.StatusOK, gin.H{
 			"error":             "access_denied",
@@ -89,13 +91,24 @@ func postLoginOauthAccessToken(ctx *gin.Context) {
 		})
 		return
 	}
-	tk, _ := jwtpkg.CreateToken(&middleware.UserLoad{
+	expiresIn := session.ExpiresIn(config.FromContext(ctx).Auth.LoginTokenExpiresIn)
+	tk, err := createUserToken(ctx, &middleware.UserLoad{
 		UserDisplayName:  cliAuthInfo.DisplayUserName,
 		CardCode:         u.CardCode,
 		Client:           cliAuthInfo.ClientId,
-		RegisteredClaims: jwtpkg.CreateStandardClaims(t.Unix(), "user"),
+		UserID:           u.UserID,
+		TokenID:          u.TokenID,
+		RegisteredClaims: jwtpkg.CreateStandardClaims(int64(expiresIn/time.Second), "user"),
 	})
-	_ = github_auth.RemoveClientAuthInfoByDeviceCode(cliAuthInfo.ClientId)
+	if err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "failed to create login session", "error", err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"error":             "server_error",
+			"error_description": "Failed to create login session.",
+		})
+		return
+	}
+	_ = github_auth.RemoveClientAuthInfoByDeviceCode(ctx, cliAuthInfo.DeviceCode)
 	ctx.JSON(http.StatusOK, gin.H{
 		"access_token": tk,
 		"scope":        "",
@@ -103,6 +116,23 @@ func postLoginOauthAccessToken(ctx *gin.Context) {
 	})
 }
 
+// createUserToken 签发插件使用的 JWT 并记录登录会话, 会话 ID 写入 jti, 用于撤销
+func createUserToken(ctx *gin.Context, load *middleware.UserLoad) (string, error) {
+	load.ID = session.NewID()
+	err := session.Create(&session.Session{
+		ID:              load.ID,
+		UserID:          load.UserID,
+		ClientID:        load.Client,
+		UserDisplayName: load.UserDisplayName,
+		CreatedAt:       time.Now(),
+		ExpiresAt:       load.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", err
+	}
+	return jwtpkg.CreateToken(load)
+}
+
 func postLoginDevice(ctx *gin.Context) {
 	var info loginDeviceRequestInfo
 	if err := response.BindStruct(ctx, &info); err != nil {
@@ -113,7 +143,7 @@ func postLoginDevice(ctx *gin.Context) {
 		return
 	}
 	// 验证密码
-	loginPassword := os.Getenv("LOGIN_PASSWORD")
+	loginPassword := config.FromContext(ctx).Auth.LoginPassword
 	if loginPassword != "" && info.Password != loginPassword {
 		response.FailJson(ctx, response.FailStruct{
 			Code: 422,
@@ -122,7 +152,7 @@ func postLoginDevice(ctx *gin.Context) {
 		return
 	}
 	// 检查code是否存在
-	authInfo, err := github_auth.GetClientAuthInfo(info.Code)
+	authInfo, err := github_auth.GetClientAuthInfo(ctx, info.Code)
 	if err != nil {
 		response.FailJson(ctx, response.FailStruct{
 			Code: 422,
@@ -131,7 +161,18 @@ func postLoginDevice(ctx *gin.Context) {
 		return
 	}
 
-	err = github_auth.UpdateClientAuthStatusByDeviceCode(authInfo.DeviceCode, info.Authorization, info.DisplayUserName)
+	if users.Enabled() {
+		err = bindDeviceCodeToUser(ctx, authInfo.DeviceCode, info)
+	} else {
+		err = github_auth.UpdateClientAuthStatusByDeviceCode(ctx, authInfo.DeviceCode, info.Authorization, info.DisplayUserName)
+	}
+	if errors.Is(err, users.ErrInvalidToken) || errors.Is(err, users.ErrTokenExpired) || errors.Is(err, users.ErrUserDisabled) {
+		response.FailJson(ctx, response.FailStruct{
+			Code: 422,
+			Msg:  "访问令牌无效",
+		}, false)
+		return
+	}
 	if err != nil {
 		response.FailJson(ctx, response.FailStruct{
 			Code: 500,
@@ -142,6 +183,23 @@ func postLoginDevice(ctx *gin.Context) {
 	response.SuccessJson(ctx, "ok")
 }
 
+// bindDeviceCodeToUser 用户模式下校验访问令牌, 并将设备代码绑定到令牌所属的用户
+// 未填写 GitHub 用户名时使用用户的显示名称
+func bindDeviceCodeToUser(ctx *gin.Context, deviceCode string, info loginDeviceRequestInfo) error {
+	user, token, err := users.Default().Authenticate(info.Authorization)
+	if err != nil {
+		return err
+	}
+	displayUserName := info.DisplayUserName
+	if displayUserName == "" {
+		displayUserName = user.DisplayName
+	}
+	if displayUserName == "" {
+		displayUserName = user.Name
+	}
+	return github_auth.UpdateClientAuthUserByDeviceCode(ctx, deviceCode, user.ID, token.ID, displayUserName)
+}
+
 func getLoginDevice(ctx *gin.Context) {
 	ctx.Header("Content-Type", "text/html; charset=utf-8")
 	ctx.HTML(http.StatusOK, "code.html", gin.H{})
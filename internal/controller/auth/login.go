@@ -2,10 +2,11 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
-	"os"
-	"time"
+	"ripper/internal/app/httpclient"
+	"ripper/internal/config"
 
 	"github.com/gin-gonic/gin"
 	"ripper/internal/response"
@@ -75,7 +76,16 @@ func makeRequest(c *gin.Context, method, url string, body map[string]string) (in
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(c, method, url, bytes.NewBuffer(jsonBody))
+	// 与代理 GitHub Copilot 的请求共用客户端, HTTP_CLIENT_TIMEOUT 限制整个请求的时长
+	cfg := config.FromContext(c)
+	client, err := httpclient.Get("github", httpclient.FromConfig(cfg.Upstream))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.HTTPTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +97,6 @@ func makeRequest(c *gin.Context, method, url string, body map[string]string) (in
 	req.Header.Set("user-agent", "GithubCopilot/1.228.0")
 	req.Header.Set("editor-version", "JetBrains-IU/242.21829.142")
 
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{Timeout: httpClientTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
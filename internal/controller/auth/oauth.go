@@ -1,12 +1,13 @@
 package auth
 
 import (
-	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"os"
 	"ripper/internal/app/github_auth"
+	"ripper/internal/app/session"
+	"ripper/internal/app/users"
 	"ripper/internal/cache"
+	"ripper/internal/config"
 	"ripper/internal/middleware"
 	"ripper/internal/response"
 	jwtpkg "ripper/pkg/jwt"
@@ -32,7 +33,16 @@ func getLoginOauthAuthorize(ctx *gin.Context) {
 		return
 	}
 
-	vsCopilotClientId := os.Getenv("VS_COPILOT_CLIENT_ID")
+	// 授权码流程无法填写访问令牌, 用户模式下不可用
+	if users.Enabled() {
+		response.FailJson(ctx, response.FailStruct{
+			Code: -1,
+			Msg:  "OAuth authorization is not available when user auth is enabled.",
+		}, false)
+		return
+	}
+
+	vsCopilotClientId := config.FromContext(ctx).Auth.VSCopilotClientID
 	if req.ClientId != vsCopilotClientId {
 		response.FailJson(ctx, response.FailStruct{
 			Code: -1,
@@ -47,9 +57,7 @@ func getLoginOauthAuthorize(ctx *gin.Context) {
 		Code:     oauthCode,
 		Scope:    req.Scope,
 	}
-	cacheKey := "oauth2_authorize_" + req.ClientId
-	caiInfo, _ := json.Marshal(cai)
-	err = cache.Set(cacheKey, caiInfo, 300)
+	err = cache.Set(ctx, github_auth.OAuthCodeKey(req.ClientId), cai, 300)
 	if err != nil {
 		response.FailJson(ctx, response.FailStruct{
 			Code: -1,
@@ -73,13 +81,19 @@ func postLoginOauthAccessTokenForVs2022(ctx *gin.Context) {
 		return
 	}
 	cliAuthInfo := v.(*github_auth.ClientOAuthInfo)
-	t := time.Now()
-	t.Add(24 * 3 * time.Hour)
-	tk, _ := jwtpkg.CreateToken(&middleware.UserLoad{
+	expiresIn := session.ExpiresIn(config.FromContext(ctx).Auth.LoginTokenExpiresIn)
+	tk, err := createUserToken(ctx, &middleware.UserLoad{
 		CardCode:         cliAuthInfo.Code,
 		Client:           cliAuthInfo.ClientId,
-		RegisteredClaims: jwtpkg.CreateStandardClaims(t.Unix(), "user"),
+		RegisteredClaims: jwtpkg.CreateStandardClaims(int64(expiresIn/time.Second), "user"),
 	})
+	if err != nil {
+		response.FailJson(ctx, response.FailStruct{
+			Code: -1,
+			Msg:  "Internal error.",
+		}, false)
+		return
+	}
 	ctx.JSON(http.StatusOK, gin.H{
 		"access_token": tk,
 		"scope":        cliAuthInfo.Scope,
@@ -93,8 +107,9 @@ func getSiteSha(ctx *gin.Context) {
 }
 
 func getLoginConfig(ctx *gin.Context) {
-	loginPassword := os.Getenv("LOGIN_PASSWORD")
+	loginPassword := config.FromContext(ctx).Auth.LoginPassword
 	ctx.JSON(http.StatusOK, gin.H{
 		"is_login_password": loginPassword != "",
+		"is_user_auth":      users.Enabled(),
 	})
 }
@@ -3,19 +3,18 @@ package copilot
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"ripper/internal/app/provider"
+	"ripper/internal/app/tracing"
+	"ripper/internal/middleware"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gofrs/uuid"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,35 +23,26 @@ import (
 func ChatCompletions(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	// 添加响应头, 解决vscode校验github所属问题
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
-
 	body, err := io.ReadAll(c.Request.Body)
 	if nil != err {
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
+	// 根据客户端选择的模型解析上游路由
 	apiModelName := gjson.GetBytes(body, "model").String()
-	// 默认设置的对话模型
-	envModelName := os.Getenv("CHAT_API_MODEL_NAME")
-	// 默认设置的对话请求地址
-	chatAPIURL := os.Getenv("CHAT_API_BASE")
-	// 默认设置的对话模型key
-	apiKey := os.Getenv("CHAT_API_KEY")
-
-	// 轻量模型直接走代码补全接口, 节约成本
-	if strings.Contains(apiModelName, os.Getenv("LIGHTWEIGHT_MODEL")) {
-		envModelName = os.Getenv("CODEX_API_MODEL_NAME")
-		codexAPIURL := os.Getenv("CODEX_API_BASE")
-		chatAPIURL = strings.Replace(codexAPIURL, "/v1/completions", "/v1/chat/completions", 1)
-		apiKey = os.Getenv("CODEX_API_KEY")
+	route, err := provider.Current().Resolve(apiModelName)
+	if nil != err {
+		slog.WarnContext(ctx, "resolve chat route failed", "error", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
 	}
+	profile := route.Profile
 
 	c.Header("Content-Type", "text/event-stream")
 
-	body, _ = sjson.SetBytes(body, "model", envModelName)
+	// 按上游模型配置改写请求体
+	_, rewriteSpan := tracing.Start(ctx, "RewriteChatBody")
 	body, _ = sjson.SetBytes(body, "stream", true) // 强制流式输出
 
 	if !gjson.GetBytes(body, "function_call").Exists() {
@@ -64,7 +54,7 @@ func ChatCompletions(c *gin.Context) {
 			}
 		}
 		lastIndex := len(messages) - 1
-		chatLocale := os.Getenv("CHAT_LOCALE")
+		chatLocale := profile.Locale
 		if chatLocale != "" && !strings.Contains(messages[lastIndex].Get("content").String(), "Respond in the following locale") {
 			body, _ = sjson.SetBytes(body, "messages."+strconv.Itoa(lastIndex)+".content", messages[lastIndex].Get("content").String()+"Respond in the following locale: "+chatLocale+".")
 		}
@@ -76,8 +66,7 @@ func ChatCompletions(c *gin.Context) {
 	body, _ = sjson.DeleteBytes(body, "logprobs") // #IBZYCA
 
 	// 是否支持使用工具, 避免模型不支持相关功能报错
-	chatUseTools, _ := strconv.ParseBool(os.Getenv("CHAT_USE_TOOLS"))
-	if !chatUseTools {
+	if !profile.UseTools {
 		body, _ = sjson.DeleteBytes(body, "tools")
 		body, _ = sjson.DeleteBytes(body, "tool_call")
 		body, _ = sjson.DeleteBytes(body, "functions")
@@ -102,14 +91,14 @@ func ChatCompletions(c *gin.Context) {
 			}
 		}
 	}
-	ChatMaxTokens, _ := strconv.Atoi(os.Getenv("CHAT_MAX_TOKENS"))
-	if int(gjson.GetBytes(body, "max_tokens").Int()) > ChatMaxTokens {
-		body, _ = sjson.SetBytes(body, "max_tokens", ChatMaxTokens)
+	if int(gjson.GetBytes(body, "max_tokens").Int()) > profile.MaxTokens {
+		body, _ = sjson.SetBytes(body, "max_tokens", profile.MaxTokens)
 	}
 
 	if gjson.GetBytes(body, "n").Int() > 1 {
 		body, _ = sjson.SetBytes(body, "n", 1)
 	}
+	rewriteSpan.End()
 
 	messages := gjson.GetBytes(body, "messages").Array()
 	userAgent := c.GetHeader("User-Agent")
@@ -141,43 +130,50 @@ func ChatCompletions(c *gin.Context) {
 		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, chatAPIURL, io.NopCloser(bytes.NewBuffer(body)))
-	if nil != err {
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	resp, err := client.Do(req)
+	// 按路由顺序请求上游, 失败时自动切换备用上游
+	resp, upstream, err := route.Chain.Do(ctx, func(u *provider.Upstream, apiKey string) (*http.Request, error) {
+		// 由适配器将请求体转换为上游格式
+		_, span := tracing.Start(ctx, "BuildRequest")
+		defer span.End()
+		span.SetAttr("upstream.provider", u.Provider.Name)
+		return provider.ChatAdapter(u.Provider).BuildRequest(ctx, u, apiKey, body)
+	})
 	if nil != err {
 		if errors.Is(err, context.Canceled) {
 			c.AbortWithStatus(http.StatusRequestTimeout)
 			return
 		}
 
-		log.Println("request conversation failed:", err.Error())
-		c.AbortWithStatus(http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "request conversation failed", "error", err)
+		c.AbortWithStatus(http.StatusServiceUnavailable)
 		return
 	}
 	defer CloseIO(resp.Body)
+	middleware.SetUpstream(c, upstream.Provider.Name, upstream.Model)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Println("request completions failed:", string(body))
+		slog.ErrorContext(ctx, "request conversation failed", "status", resp.StatusCode, "body", string(body))
 
+		// 内容过滤以正常结束的对话流返回, 便于客户端展示过滤原因
+		if message, ok := provider.ContentFilterMessage(body); ok {
+			c.Status(http.StatusOK)
+			_ = provider.WriteContentFilter(c.Writer, apiModelName, message)
+			return
+		}
 		resp.Body = io.NopCloser(bytes.NewBuffer(body))
 	}
 
 	c.Status(resp.StatusCode)
-	_, _ = io.Copy(c.Writer, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(c.Writer, resp.Body)
+		return
+	}
+
+	// 由适配器将上游响应转换为 chat.completion.chunk 格式
+	if err := provider.ChatAdapter(upstream.Provider).Stream(c.Writer, resp, apiModelName); nil != err {
+		slog.ErrorContext(ctx, "stream conversation failed", "error", err)
+	}
 }
 
 // vs2022FirstChatTemplate is a template for the first chat completion response
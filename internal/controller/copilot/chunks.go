@@ -5,40 +5,21 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
+	"ripper/internal/app/tracing"
+	"ripper/internal/config"
+	"ripper/internal/middleware"
 	"strings"
 	"sync"
 
-	"github.com/gofrs/uuid"
-
 	"github.com/gin-gonic/gin"
 )
 
 // 常量定义
 const (
-	defaultDimensionSize = 1536 // 默认向量维度
-	markdownFilePrefix   = "File: `%s`\n```shell\n"
-	markdownFileSuffix   = "```"
+	markdownFilePrefix = "File: `%s`\n```shell\n"
+	markdownFileSuffix = "```"
 )
 
-// 获取向量维度大小
-func getDimensionSize() int {
-	dimensionSize := defaultDimensionSize
-	if dimSizeStr := os.Getenv("EMBEDDING_DIMENSION_SIZE"); dimSizeStr != "" {
-		if dimSize, err := strconv.Atoi(dimSizeStr); err == nil {
-			dimensionSize = dimSize
-		}
-	}
-	return dimensionSize
-}
-
-// 计算块大小
-func getChunkSize() int {
-	// 根据维度大小调整块大小，这里设置为维度的1.5倍左右
-	return getDimensionSize() * 3 / 2
-}
-
 // ChunkRequest 表示分块请求
 type ChunkRequest struct {
 	Content string `json:"content" binding:"required"`
@@ -77,18 +58,21 @@ type ChunkResponse struct {
 type ChunkService struct {
 	embeddingClient *EmbeddingClient
 	modelName       string
+	chunkSize       int
 }
 
 // NewChunkService 创建新的分块服务
-func NewChunkService() (*ChunkService, error) {
-	client, err := NewEmbeddingClient(getDimensionSize())
+func NewChunkService(cfg *config.Config) (*ChunkService, error) {
+	client, err := NewEmbeddingClient(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding client: %w", err)
 	}
 
 	return &ChunkService{
 		embeddingClient: client,
-		modelName:       os.Getenv("EMBEDDING_API_MODEL_NAME"),
+		modelName:       cfg.Embedding.ModelName,
+		// 根据维度大小调整块大小，这里设置为维度的1.5倍左右
+		chunkSize: cfg.Embedding.DimensionSize * 3 / 2,
 	}, nil
 }
 
@@ -100,11 +84,12 @@ func HandleChunks(c *gin.Context) {
 		return
 	}
 
-	service, err := NewChunkService()
+	service, err := NewChunkService(config.FromContext(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to initialize service: %v", err)})
 		return
 	}
+	middleware.SetUpstream(c, service.embeddingClient.upstream.Name, service.embeddingClient.apiModel)
 
 	chunks := service.SplitIntoChunks(req.Content, req.Path, service.modelName)
 
@@ -120,8 +105,6 @@ func HandleChunks(c *gin.Context) {
 		EmbeddingModel: service.modelName,
 	}
 
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -129,7 +112,7 @@ func HandleChunks(c *gin.Context) {
 func (s *ChunkService) SplitIntoChunks(content, path string, model string) []Chunk {
 	var chunks []Chunk
 	lines := strings.Split(content, "\n")
-	chunkSize := getChunkSize()
+	chunkSize := s.chunkSize
 
 	// 预分配切片容量，减少内存重新分配
 	estimatedChunks := len(content)/chunkSize + 1
@@ -221,6 +204,10 @@ func (s *ChunkService) generateEmbeddingsSerial(ctx context.Context, chunks []Ch
 
 // generateEmbeddingsParallel 并行生成嵌入向量
 func (s *ChunkService) generateEmbeddingsParallel(ctx context.Context, chunks []Chunk) error {
+	ctx, span := tracing.Start(ctx, "generateEmbeddingsParallel")
+	defer span.End()
+	span.SetAttr("chunks", len(chunks))
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(chunks))
 
@@ -236,10 +223,15 @@ func (s *ChunkService) generateEmbeddingsParallel(ctx context.Context, chunks []
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
+			chunkCtx, chunkSpan := tracing.Start(ctx, "GetEmbedding")
+			defer chunkSpan.End()
+			chunkSpan.SetAttr("chunk", idx)
+
 			text := s.extractPlainText(chunks[idx].Text)
 
-			embedding, err := s.embeddingClient.GetEmbedding(ctx, text)
+			embedding, err := s.embeddingClient.GetEmbedding(chunkCtx, text)
 			if err != nil {
+				chunkSpan.SetError(err)
 				errChan <- fmt.Errorf("failed to generate embedding for chunk %d: %w", idx, err)
 				return
 			}
@@ -4,16 +4,16 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
-	"log"
-	"math/rand"
+	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
+	"ripper/internal/app/fim"
+	"ripper/internal/app/provider"
+	"ripper/internal/app/tracing"
+	"ripper/internal/config"
+	"ripper/internal/middleware"
 	"strings"
 	"time"
 
@@ -25,15 +25,15 @@ import (
 
 // CodeCompletions 代码补全
 func CodeCompletions(c *gin.Context) {
+	cfg := config.FromContext(c)
 	ctx := c.Request.Context()
 
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
-
-	debounceTime, _ := strconv.Atoi(os.Getenv("COPILOT_DEBOUNCE"))
-	time.Sleep(time.Duration(debounceTime) * time.Millisecond)
+	_, debounceSpan := tracing.Start(ctx, "debounce")
+	time.Sleep(time.Duration(cfg.Codex.Debounce) * time.Millisecond)
+	debounceSpan.End()
 
 	if ctx.Err() != nil {
+		middleware.MarkDebounceCancelled(c)
 		abortCodex(c, http.StatusRequestTimeout)
 		return
 	}
@@ -44,66 +44,65 @@ func CodeCompletions(c *gin.Context) {
 		return
 	}
 
-	c.Header("Content-Type", "text/event-stream")
-	codexServiceType := os.Getenv("CODEX_SERVICE_TYPE")
-	body = ConstructRequestBody(body, codexServiceType)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, os.Getenv("CODEX_API_BASE"), io.NopCloser(bytes.NewBuffer(body)))
+	route, err := provider.Current().ResolveCompletions(gjson.GetBytes(body, "model").String())
 	if nil != err {
+		slog.ErrorContext(ctx, "resolve completions route failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	apiKeys := strings.Split(os.Getenv("CODEX_API_KEY"), ",")
-
-	// 检查 apiKeys 是否有效
-	if len(apiKeys) == 0 || (len(apiKeys) == 1 && apiKeys[0] == "") {
-		abortCodex(c, http.StatusInternalServerError)
-		return
-	}
-
-	randGen := rand.New(rand.NewSource(time.Now().UnixNano()))
-	selectedKey := strings.TrimSpace(apiKeys[randGen.Intn(len(apiKeys))])
-
-	if selectedKey == "" {
-		abortCodex(c, http.StatusInternalServerError)
-		return
-	}
+	c.Header("Content-Type", "text/event-stream")
+	// 插件未请求 usage 时, 为统计用量向 OpenAI 兼容上游请求的 usage chunk 不转发给插件
+	stripUsage := false
+	// 按路由顺序请求上游, 失败时自动切换备用上游
+	resp, upstream, err := route.Chain.Do(ctx, func(u *provider.Upstream, selectedKey string) (*http.Request, error) {
+		_, span := tracing.Start(ctx, "ConstructRequestBody")
+		upstreamBody := ConstructRequestBody(&cfg.Codex, body, u.Provider.Type, u.Model)
+		span.End()
+		stripUsage = false
+		if u.Provider.Type != provider.TypeOllama {
+			var requested bool
+			upstreamBody, requested = provider.IncludeUsage(upstreamBody)
+			stripUsage = !requested
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.Provider.URL(u.Model, "completions"), io.NopCloser(bytes.NewBuffer(upstreamBody)))
+		if nil != err {
+			return nil, err
+		}
 
-	req.Header.Set("Authorization", "Bearer "+selectedKey)
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	resp, err := client.Do(req)
+		req.Header.Set("Content-Type", "application/json")
+		u.Provider.SetAuth(req, selectedKey)
+		return req, nil
+	})
 	if nil != err {
 		if errors.Is(err, context.Canceled) {
 			abortCodex(c, http.StatusRequestTimeout)
 			return
 		}
 
-		log.Println("request completions failed:", err.Error())
-		abortCodex(c, http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "request completions failed", "error", err)
+		abortCodex(c, http.StatusServiceUnavailable)
 		return
 	}
 	defer CloseIO(resp.Body)
+	middleware.SetUpstream(c, upstream.Provider.Name, upstream.Model)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Println("request completions failed:", string(body))
+		slog.ErrorContext(ctx, "request completions failed", "status", resp.StatusCode, "body", string(body))
 
+		// 内容过滤不视为错误, 直接返回空补全
+		if _, ok := provider.ContentFilterMessage(body); ok {
+			abortCodex(c, http.StatusOK)
+			return
+		}
 		abortCodex(c, resp.StatusCode)
 		return
 	}
 
 	c.Status(resp.StatusCode)
 	// 处理 Ollama 服务的流式响应
-	if codexServiceType == "ollama" {
+	if upstream.Provider.Type == provider.TypeOllama {
 		reader := bufio.NewReader(resp.Body)
 		for {
 			line, err := reader.ReadString('\n')
@@ -169,26 +168,27 @@ func CodeCompletions(c *gin.Context) {
 	}
 
 	// 处理默认服务的响应
-	_, _ = io.Copy(c.Writer, resp.Body)
+	if err := provider.CopyStream(c.Writer, resp.Body, stripUsage); err != nil {
+		slog.ErrorContext(ctx, "stream completions failed", "error", err)
+	}
 }
 
 // ConstructRequestBody 重新构建请求体
-func ConstructRequestBody(body []byte, codexServiceType string) []byte {
-	envCodexModel := os.Getenv("CODEX_API_MODEL_NAME")
-	body, _ = sjson.SetBytes(body, "model", envCodexModel)
+func ConstructRequestBody(codex *config.CodexConfig, body []byte, codexServiceType string, codexModel string) []byte {
+	language := gjson.GetBytes(body, "extra.language").String()
+	body, _ = sjson.SetBytes(body, "model", codexModel)
 	body, _ = sjson.SetBytes(body, "stream", true) // 强制流式输出
 	body, _ = sjson.DeleteBytes(body, "extra")
 	body, _ = sjson.DeleteBytes(body, "nwo")
 
 	// 限制 prompt 和 suffix 的长度
-	body = applyPromptLengthLimit(body)
+	body = applyPromptLengthLimit(body, codex.LimitPrompt)
 
-	temperature, _ := strconv.ParseFloat(os.Getenv("CODEX_TEMPERATURE"), 64)
-	if temperature != -1 {
-		body, _ = sjson.SetBytes(body, "temperature", temperature)
+	if codex.Temperature != -1 {
+		body, _ = sjson.SetBytes(body, "temperature", codex.Temperature)
 	}
 
-	codeMaxTokens, _ := strconv.Atoi(os.Getenv("CODEX_MAX_TOKENS"))
+	codeMaxTokens := codex.MaxTokens
 	if int(gjson.GetBytes(body, "max_tokens").Int()) > codeMaxTokens {
 		body, _ = sjson.SetBytes(body, "max_tokens", codeMaxTokens)
 	}
@@ -197,23 +197,16 @@ func ConstructRequestBody(body []byte, codexServiceType string) []byte {
 		body, _ = sjson.SetBytes(body, "n", 1)
 	}
 
-	// https://ollama.com/library/stable-code || https://ollama.com/library/codegemma
-	if strings.Contains(envCodexModel, "stable-code") || strings.Contains(envCodexModel, "codegemma") {
-		return constructWithStableCodeModel(body)
-	}
-
-	// https://ollama.com/library/codellama
-	if strings.Contains(envCodexModel, "codellama") {
-		return constructWithCodeLlamaModel(body)
-	}
-
-	// https://help.aliyun.com/zh/model-studio/user-guide/qwen-coder?spm=a2c4g.11186623.0.0.a5234823I6LvAG
-	if strings.Contains(envCodexModel, "qwen-coder-turbo") {
-		return constructWithQwenCoderTurboModel(body)
+	// 按模型匹配 FIM 模板, 详细参考 FIM_TEMPLATES_FILE
+	if template, ok := fim.Find(codexModel); ok {
+		body = template.Apply(body, language)
+		if template.Mode == fim.ModeChat || codexServiceType != provider.TypeOllama {
+			return body
+		}
 	}
 
 	// 支持 Ollama FIM 的模型, 如:https://ollama.com/library/deepseek-coder-v2
-	if codexServiceType == "ollama" {
+	if codexServiceType == provider.TypeOllama {
 		return constructWithOllamaModel(body, codeMaxTokens)
 	}
 
@@ -221,10 +214,8 @@ func ConstructRequestBody(body []byte, codexServiceType string) []byte {
 }
 
 // applyPromptLengthLimit 对 prompt 和 suffix 应用长度限制
-func applyPromptLengthLimit(body []byte) []byte {
-	envLimitPrompt := os.Getenv("CODEX_LIMIT_PROMPT")
-	limitPrompt, err := strconv.Atoi(envLimitPrompt)
-	if err != nil || limitPrompt <= 0 {
+func applyPromptLengthLimit(body []byte, limitPrompt int) []byte {
+	if limitPrompt <= 0 {
 		return body
 	}
 
@@ -272,78 +263,6 @@ func limitSuffixLength(body []byte, limitRows int) []byte {
 	return body
 }
 
-// constructWithCodeLlamaModel 重写codeLlama模型要求的请求体
-func constructWithCodeLlamaModel(body []byte) []byte {
-	suffix := gjson.GetBytes(body, "suffix")
-	prompt := gjson.GetBytes(body, "prompt")
-	content := fmt.Sprintf("<PRE> %s <SUF> %s <MID>", prompt, suffix)
-
-	return constructWithChatModel(body, content)
-}
-
-// constructWithStableCodeModel 重写StableCode模型要求的请求体
-func constructWithStableCodeModel(body []byte) []byte {
-	suffix := gjson.GetBytes(body, "suffix")
-	prompt := gjson.GetBytes(body, "prompt")
-	content := fmt.Sprintf("<fim_prefix>%s<fim_suffix>%s<fim_middle>", prompt, suffix)
-
-	return constructWithChatModel(body, content)
-}
-
-// constructWithChatModel 重写Chat请求体
-func constructWithChatModel(body []byte, content string) []byte {
-	// 创建新的 JSON 对象并添加到 body 中
-	messages := []map[string]string{
-		{
-			"role":    "user",
-			"content": content,
-		},
-	}
-
-	body, _ = sjson.SetBytes(body, "messages", messages)
-
-	jsonStr := string(body)
-	jsonStr = strings.ReplaceAll(jsonStr, "\\u003c", "<")
-	jsonStr = strings.ReplaceAll(jsonStr, "\\u003e", ">")
-	return []byte(jsonStr)
-}
-
-// constructWithQwenCoderTurboModel 重写QwenCoderTurbo模型要求的请求体
-func constructWithQwenCoderTurboModel(body []byte) []byte {
-	if gjson.GetBytes(body, "n").Int() > 1 {
-		body, _ = sjson.SetBytes(body, "n", 1)
-	}
-	suffix := gjson.GetBytes(body, "suffix")
-	prompt := gjson.GetBytes(body, "prompt")
-	codeLanguage := gjson.GetBytes(body, "extra.language")
-
-	messages := []map[string]interface{}{
-		{
-			"role":    "system",
-			"content": "You are an expert in " + codeLanguage.Str + " programming, highly skilled at understanding and continuing to write code.",
-		},
-		{
-			"role": "user",
-			"content": "Combined with subsequent code snippets, help me complete the code:\n\n" +
-				"Code subsequent content:\n```" + codeLanguage.Str + "\n" + suffix.Str + "```\n\n" +
-				"Remember:\n" +
-				"- Do not generate content outside of the code.\n" +
-				"- Do not directly fill in all the code content, the maximum number of lines of code should not exceed 5 lines.\n" +
-				"- Answer must refer to the code suffix content, do not exceed the boundary, otherwise repeated code will occur.\n" +
-				"- If you don't know how to answer, just reply with an empty string.",
-		},
-		{
-			"role":    "assistant",
-			"content": prompt.Str,
-			"partial": true,
-		},
-	}
-
-	body, _ = sjson.SetBytes(body, "messages", messages)
-	body, _ = sjson.DeleteBytes(body, "prompt")
-	return body
-}
-
 // constructWithOllamaModel 重写Ollama模型要求的请求体
 func constructWithOllamaModel(body []byte, codeMaxTokens int) []byte {
 	body, _ = sjson.SetBytes(body, "options.temperature", 0)
@@ -3,19 +3,19 @@ package copilot
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"ripper/internal/app/keypool"
+	"ripper/internal/app/metrics"
+	"ripper/internal/app/provider"
+	"ripper/internal/config"
 	"sync"
-	"time"
 )
 
 // 常量定义
 const (
-	defaultTimeout  = 30 * time.Second
 	contentTypeJSON = "application/json"
 )
 
@@ -54,48 +54,36 @@ type Usage struct {
 
 // EmbeddingClient 封装了与嵌入API交互的功能
 type EmbeddingClient struct {
-	apiURL      string
-	apiKey      string
+	upstream    *provider.Provider
+	keys        *keypool.Pool
+	apiModel    string // 上游真实模型名称
 	model       string
 	dimensions  int
-	httpClient  *http.Client
 	clientMutex sync.RWMutex
 }
 
 // NewEmbeddingClient 创建一个新的嵌入客户端
-func NewEmbeddingClient(dimensions int) (*EmbeddingClient, error) {
-	apiURL := os.Getenv("EMBEDDING_API_BASE")
-	apiKey := os.Getenv("EMBEDDING_API_KEY")
-
-	if apiURL == "" || apiKey == "" {
-		return nil, fmt.Errorf("EMBEDDING_API_BASE or EMBEDDING_API_KEY environment variable not set")
-	}
-
-	if os.Getenv("EMBEDDING_API_MODEL_NAME") == "" {
-		return nil, fmt.Errorf("EMBEDDING_API_MODEL_NAME environment variable not set")
+func NewEmbeddingClient(cfg *config.Config) (*EmbeddingClient, error) {
+	embedding := cfg.Embedding
+	if embedding.APIBase == "" || len(embedding.APIKeys) == 0 {
+		return nil, fmt.Errorf("EMBEDDING_API_BASE or EMBEDDING_API_KEY is not configured")
 	}
 
-	// 解析超时时间，如果未设置或解析失败则使用默认值
-	timeout := defaultTimeout
-	if timeoutStr := os.Getenv("HTTP_CLIENT_TIMEOUT"); timeoutStr != "" {
-		if parsedTimeout, err := time.ParseDuration(timeoutStr + "s"); err == nil {
-			timeout = parsedTimeout
-		}
+	if embedding.ModelName == "" {
+		return nil, fmt.Errorf("EMBEDDING_API_MODEL_NAME is not configured")
 	}
 
-	client := &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	serviceType := provider.TypeOpenAI
+	if embedding.ServiceType == provider.TypeAzure {
+		serviceType = provider.TypeAzure
 	}
 
 	return &EmbeddingClient{
-		apiURL:     apiURL,
-		apiKey:     apiKey,
-		model:      os.Getenv("EMBEDDING_API_MODEL_NAME"),
-		dimensions: dimensions,
-		httpClient: client,
+		upstream:   &provider.Provider{Name: "embedding", Type: serviceType, APIBase: embedding.APIBase},
+		keys:       keypool.Get("embedding", embedding.APIKeys, ""),
+		apiModel:   embedding.ModelName,
+		model:      embedding.ModelName,
+		dimensions: embedding.DimensionSize,
 	}, nil
 }
 
@@ -127,7 +115,7 @@ func (c *EmbeddingClient) GetEmbeddings(ctx context.Context, texts []string) (*E
 	c.clientMutex.RUnlock()
 
 	reqBody := EmbeddingRequest{
-		Model:      os.Getenv("EMBEDDING_API_MODEL_NAME"),
+		Model:      c.apiModel,
 		Input:      texts,
 		Dimensions: dimensions,
 	}
@@ -137,19 +125,33 @@ func (c *EmbeddingClient) GetEmbeddings(ctx context.Context, texts []string) (*E
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.upstream.URL(reqBody.Model, "embeddings"), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
+	key, err := c.keys.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire api key: %v", err)
+	}
+
 	req.Header.Set("Content-Type", contentTypeJSON)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	c.upstream.SetAuth(req, key.Value())
 
-	resp, err := c.httpClient.Do(req)
+	client, err := c.upstream.Client()
 	if err != nil {
+		c.keys.Report(ctx, key, 0)
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		c.keys.Report(ctx, key, 0)
+		metrics.ObserveUpstream(c.upstream.Name, 0, err)
 		return nil, fmt.Errorf("failed to make request: %v", err)
 	}
 	defer resp.Body.Close()
+	c.keys.Report(ctx, key, resp.StatusCode)
+	metrics.ObserveUpstream(c.upstream.Name, resp.StatusCode, nil)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -157,6 +159,9 @@ func (c *EmbeddingClient) GetEmbeddings(ctx context.Context, texts []string) (*E
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		if message, ok := provider.ContentFilterMessage(body); ok {
+			return nil, fmt.Errorf("embedding input was filtered: %s", message)
+		}
 		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
@@ -1,12 +1,10 @@
 package copilot
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
-
-	"github.com/gofrs/uuid"
+	"ripper/internal/config"
+	"ripper/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,8 +18,6 @@ type EmbeddingsAPIRequest struct {
 
 // HandleEmbeddings 处理嵌入请求的HTTP处理器
 func HandleEmbeddings(c *gin.Context) {
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
 	// body, err := io.ReadAll(c.Request.Body)
 	// log.Println("Raw body:", string(body))
 	// c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
@@ -38,25 +34,18 @@ func HandleEmbeddings(c *gin.Context) {
 	// println(string(jsonBytes))
 	var req EmbeddingsAPIRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Println("Binding error:", err)
+		slog.WarnContext(c.Request.Context(), "binding embeddings request failed", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 从环境变量获取维度大小，默认为1536
-	dimensionSize := 1536
-	if dimSizeStr := os.Getenv("EMBEDDING_DIMENSION_SIZE"); dimSizeStr != "" {
-		if dimSize, err := strconv.Atoi(dimSizeStr); err == nil {
-			dimensionSize = dimSize
-		}
-	}
-
 	// 创建嵌入客户端
-	client, err := NewEmbeddingClient(dimensionSize)
+	client, err := NewEmbeddingClient(config.FromContext(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.SetUpstream(c, client.upstream.Name, client.apiModel)
 
 	// 如果请求中指定了模型，则使用请求中的模型
 	if req.Model != "" {
@@ -75,13 +64,11 @@ func HandleEmbeddings(c *gin.Context) {
 
 // EmbeddingModels 获取可用的嵌入模型列表
 func EmbeddingModels(c *gin.Context) {
-	modelName := os.Getenv("EMBEDDING_API_MODEL_NAME")
+	modelName := config.FromContext(c).Embedding.ModelName
 	if modelName == "" {
 		modelName = "text-embedding-3-small"
 	}
 
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
 	c.JSON(http.StatusOK, gin.H{
 		"data": []gin.H{
 			{"id": modelName, "object": "model", "owned_by": "openai", "permission": []string{}},
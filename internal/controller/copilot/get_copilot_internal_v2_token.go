@@ -1,45 +1,55 @@
 package copilot
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
-	"log"
-	"math/rand"
+	"log/slog"
 	"net/http"
-	"os"
 	"ripper/internal/app/github_auth"
+	"ripper/internal/app/keypool"
 	"ripper/internal/cache"
-	"strconv"
-	"strings"
+	"ripper/internal/config"
+	"ripper/internal/middleware"
+	jwtpkg "ripper/pkg/jwt"
 	"time"
 )
 
 // GetDisguiseCopilotInternalV2Token 返回伪装的token
 func GetDisguiseCopilotInternalV2Token(ctx *gin.Context) {
-	requestID := uuid.Must(uuid.NewV4()).String()
-	ctx.Header("x-github-request-id", requestID)
-
+	cfg := config.FromContext(ctx)
 	trackingId, _ := uuid.NewV4()
 	now := time.Now().Unix()
-	dcAt, _ := strconv.Atoi(os.Getenv("DISGUISE_COPILOT_TOKEN_EXPIRES_AT"))
+	dcAt := cfg.Copilot.DisguiseTokenExpiresAt
 	expiresAt := now + int64(dcAt)
 	sku := "copilot_for_business_seat"
 
-	copilotToken := github_auth.JsonMap2SignToken(map[string]interface{}{
+	claims := map[string]interface{}{
 		"tid":  trackingId,
 		"exp":  expiresAt,
 		"sku":  sku,
 		"st":   "dotcom",
 		"chat": 1,
 		"u":    "github",
-	})
+	}
+	// 记录 token 所属的用户和登录会话, 补全请求据此校验访问令牌和会话是否仍然有效
+	if load, err := jwtpkg.GetJwtProto(ctx, &middleware.UserLoad{}); err == nil {
+		if load.TokenID != "" {
+			claims["uid"] = load.UserID
+			claims["tkid"] = load.TokenID
+		}
+		if load.ID != "" {
+			claims["sid"] = load.ID
+		}
+	}
+	copilotToken := github_auth.JsonMap2SignToken(claims)
 
 	endpoints := make(map[string]interface{})
-	endpoints["api"] = os.Getenv("API_BASE_URL")
+	endpoints["api"] = cfg.Server.APIBaseURL
 	endpoints["origin-tracker"] = "https://origin-tracker.individual.githubcopilot.com"
-	endpoints["proxy"] = os.Getenv("PROXY_BASE_URL")
-	endpoints["telemetry"] = os.Getenv("TELEMETRY_BASE_URL")
+	endpoints["proxy"] = cfg.Server.ProxyBaseURL
+	endpoints["telemetry"] = cfg.Server.TelemetryBaseURL
 
 	gout := gin.H{
 		"annotations_enabled":                      true,
@@ -77,39 +87,48 @@ func GetDisguiseCopilotInternalV2Token(ctx *gin.Context) {
 
 // GetCopilotInternalV2Token 获取github copilot官方token
 func GetCopilotInternalV2Token(c *gin.Context) {
-	ghuTokens := strings.Split(os.Getenv("COPILOT_GHU_TOKEN"), ",")
-	if len(ghuTokens) == 0 {
+	cfg := config.FromContext(c)
+	cacheKey := "copilot_internal_v2_token"
+	token, ok, err := cache.Get[json.RawMessage](c, cacheKey)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "read copilot token cache failed", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		cache.Del(c, cacheKey)
 		return
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	ghu := ghuTokens[rand.Intn(len(ghuTokens))]
-	if ghu == "" {
-		log.Println("ghu token is empty")
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "ghu token is empty",
-		})
+	if ok {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", token)
 		return
 	}
 
-	cacheKey := "copilot_internal_v2_token"
-	token, err := cache.Get(cacheKey)
+	// 缓存未命中时才选取 ghu token, 避免命中缓存也计入 key 的使用次数
+	pool := keypool.Get("ghu", cfg.Copilot.GHUTokens, "")
+	key, err := pool.Acquire()
 	if err != nil {
-		log.Println(err.Error())
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		cache.Del(cacheKey)
+		slog.ErrorContext(c.Request.Context(), "ghu token is unavailable", "error", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "ghu token is unavailable",
+		})
 		return
 	}
+	ghu := key.Value()
 
-	if token != nil {
-		c.JSON(http.StatusOK, token)
+	client, err := githubClient(cfg)
+	if err != nil {
+		pool.Report(c.Request.Context(), key, 0)
+		slog.ErrorContext(c.Request.Context(), "create github client failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	reqCtx, cancel := context.WithTimeout(c.Request.Context(), cfg.HTTPTimeout())
+	defer cancel()
 
 	url := "https://api.github.com/copilot_internal/v2/token"
-	req, err := http.NewRequestWithContext(c, "GET", url, nil)
+	req, err := http.NewRequestWithContext(reqCtx, "GET", url, nil)
 	if err != nil {
-		log.Println(err.Error())
+		pool.Report(c.Request.Context(), key, 0)
+		slog.ErrorContext(c.Request.Context(), "create copilot token request failed", "error", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -119,29 +138,30 @@ func GetCopilotInternalV2Token(c *gin.Context) {
 	req.Header.Set("editor-version", "JetBrains-IU/242.21829.142")
 	req.Header.Set("user-agent", "GithubCopilot/1.228.0")
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println(err.Error())
-		c.JSON(resp.StatusCode, gin.H{"error": err.Error()})
+		pool.Report(c.Request.Context(), key, 0)
+		slog.ErrorContext(c.Request.Context(), "request copilot token failed", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
 		return
 	}
+	defer resp.Body.Close()
+	pool.Report(c.Request.Context(), key, resp.StatusCode)
 	if resp.StatusCode != 200 {
-		errorMsg := "获取 Token 失败, 当前 ghu_token 账户可能并未订阅 github copilot 服务!" + ghu
+		errorMsg := "获取 Token 失败, 当前 ghu_token 账户可能并未订阅 github copilot 服务!"
 		c.JSON(resp.StatusCode, gin.H{"error": errorMsg})
-		log.Println(errorMsg)
+		slog.ErrorContext(c.Request.Context(), errorMsg, "status", resp.StatusCode)
 		return
 	}
-	defer resp.Body.Close()
 
-	var result interface{}
+	var result json.RawMessage
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		log.Println(err.Error())
+		slog.ErrorContext(c.Request.Context(), "decode copilot token failed", "error", err)
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		return
 	}
 
-	cache.Set(cacheKey, result, 1500)
-	c.JSON(resp.StatusCode, result)
+	cache.Set(c, cacheKey, result, 1500)
+	c.Data(resp.StatusCode, "application/json; charset=utf-8", result)
 }
@@ -3,38 +3,41 @@ package copilot
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/gofrs/uuid"
 	"github.com/tidwall/gjson"
 	"io"
 	"io/ioutil"
-	"log"
-	"math/rand"
+	"log/slog"
 	"net/http"
-	"os"
+	"ripper/internal/app/httpclient"
+	"ripper/internal/app/keypool"
+	"ripper/internal/app/metrics"
+	"ripper/internal/app/tracing"
 	"ripper/internal/cache"
-	"strconv"
-	"strings"
+	"ripper/internal/config"
+	"ripper/internal/middleware"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// githubProvider 全代理模式下监控指标中的上游提供方名称
+const githubProvider = "github"
+
 // CodexCompletions 全代理GitHub的代码补全接口
 func CodexCompletions(c *gin.Context) {
+	cfg := config.FromContext(c)
 	ctx := c.Request.Context()
 
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
-
 	urlModelName := c.Param("model-name")
-	debounceTime, _ := strconv.Atoi(os.Getenv("COPILOT_DEBOUNCE"))
-	time.Sleep(time.Duration(debounceTime) * time.Millisecond)
+	_, debounceSpan := tracing.Start(ctx, "debounce")
+	time.Sleep(time.Duration(cfg.Codex.Debounce) * time.Millisecond)
+	debounceSpan.End()
 
 	if ctx.Err() != nil {
+		middleware.MarkDebounceCancelled(c)
 		abortCodex(c, http.StatusRequestTimeout)
 		return
 	}
@@ -45,44 +48,46 @@ func CodexCompletions(c *gin.Context) {
 		return
 	}
 
-	copilotAccountType := os.Getenv("COPILOT_ACCOUNT_TYPE")
+	copilotAccountType := cfg.Copilot.AccountType
 	url := "https://proxy." + copilotAccountType + ".githubcopilot.com/v1/engines/" + urlModelName + "/completions"
-	req, err := http.NewRequestWithContext(c, "POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if nil != err {
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
 	// 合并请求头
-	if err := mergeHeaders(c.Request.Header, req); err != nil {
-		log.Println(err)
+	if err := mergeHeaders(cfg, c.Request.Header, req); err != nil {
+		slog.ErrorContext(ctx, "merge headers failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	client, err := githubClient(cfg)
+	if nil != err {
+		slog.ErrorContext(ctx, "create github client failed", "error", err)
+		abortCodex(c, http.StatusInternalServerError)
+		return
 	}
 	resp, err := client.Do(req)
+	metrics.ObserveUpstream(githubProvider, 0, err)
 	if nil != err {
 		if errors.Is(err, context.Canceled) {
 			abortCodex(c, http.StatusRequestTimeout)
 			return
 		}
 
-		log.Println("request completions failed:", err.Error())
+		slog.ErrorContext(ctx, "request github failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 	defer CloseIO(resp.Body)
+	metrics.ObserveUpstream(githubProvider, resp.StatusCode, nil)
+	middleware.SetUpstream(c, githubProvider, urlModelName)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Println("请求GitHub官方补全接口失败:", string(body))
+		slog.ErrorContext(ctx, "请求GitHub官方补全接口失败", "status", resp.StatusCode, "body", string(body))
 
 		abortCodex(c, resp.StatusCode)
 		return
@@ -95,6 +100,7 @@ func CodexCompletions(c *gin.Context) {
 
 // ChatsCompletions 全代理GitHub的聊天补全接口
 func ChatsCompletions(c *gin.Context) {
+	cfg := config.FromContext(c)
 	ctx := c.Request.Context()
 	if ctx.Err() != nil {
 		abortCodex(c, http.StatusRequestTimeout)
@@ -107,7 +113,7 @@ func ChatsCompletions(c *gin.Context) {
 		return
 	}
 
-	copilotAccountType := os.Getenv("COPILOT_ACCOUNT_TYPE")
+	copilotAccountType := cfg.Copilot.AccountType
 	modelName := gjson.GetBytes(body, "model").String()
 	var url string
 	// 解决 copilot-nes-xtab 补全模型走 proxy 请求地址
@@ -116,42 +122,44 @@ func ChatsCompletions(c *gin.Context) {
 	} else {
 		url = "https://api." + copilotAccountType + ".githubcopilot.com/chat/completions"
 	}
-	req, err := http.NewRequestWithContext(c, "POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if nil != err {
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
 	// 合并请求头
-	if err := mergeHeaders(c.Request.Header, req); err != nil {
-		log.Println(err)
+	if err := mergeHeaders(cfg, c.Request.Header, req); err != nil {
+		slog.ErrorContext(ctx, "merge headers failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	client, err := githubClient(cfg)
+	if nil != err {
+		slog.ErrorContext(ctx, "create github client failed", "error", err)
+		abortCodex(c, http.StatusInternalServerError)
+		return
 	}
 	resp, err := client.Do(req)
+	metrics.ObserveUpstream(githubProvider, 0, err)
 	if nil != err {
 		if errors.Is(err, context.Canceled) {
 			abortCodex(c, http.StatusRequestTimeout)
 			return
 		}
 
-		log.Println("request completions failed:", err.Error())
+		slog.ErrorContext(ctx, "request github failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 	defer CloseIO(resp.Body)
+	metrics.ObserveUpstream(githubProvider, resp.StatusCode, nil)
+	middleware.SetUpstream(c, githubProvider, modelName)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Println("请求GitHub官方对话接口失败:", string(body))
+		slog.ErrorContext(ctx, "请求GitHub官方对话接口失败", "status", resp.StatusCode, "body", string(body))
 
 		abortCodex(c, resp.StatusCode)
 		return
@@ -164,6 +172,7 @@ func ChatsCompletions(c *gin.Context) {
 
 // ChatEditCompletions 聊天编辑补全接口
 func ChatEditCompletions(c *gin.Context) {
+	cfg := config.FromContext(c)
 	ctx := c.Request.Context()
 	if ctx.Err() != nil {
 		abortCodex(c, http.StatusRequestTimeout)
@@ -176,44 +185,46 @@ func ChatEditCompletions(c *gin.Context) {
 		return
 	}
 
-	copilotAccountType := os.Getenv("COPILOT_ACCOUNT_TYPE")
+	copilotAccountType := cfg.Copilot.AccountType
 	url := "https://proxy." + copilotAccountType + ".githubcopilot.com/v1/engines/copilot-centralus-h100/speculation"
-	req, err := http.NewRequestWithContext(c, "POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if nil != err {
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
 	// 合并请求头
-	if err := mergeHeaders(c.Request.Header, req); err != nil {
-		log.Println(err)
+	if err := mergeHeaders(cfg, c.Request.Header, req); err != nil {
+		slog.ErrorContext(ctx, "merge headers failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	client, err := githubClient(cfg)
+	if nil != err {
+		slog.ErrorContext(ctx, "create github client failed", "error", err)
+		abortCodex(c, http.StatusInternalServerError)
+		return
 	}
 	resp, err := client.Do(req)
+	metrics.ObserveUpstream(githubProvider, 0, err)
 	if nil != err {
 		if errors.Is(err, context.Canceled) {
 			abortCodex(c, http.StatusRequestTimeout)
 			return
 		}
 
-		log.Println("request failed:", err.Error())
+		slog.ErrorContext(ctx, "request github failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 	defer CloseIO(resp.Body)
+	metrics.ObserveUpstream(githubProvider, resp.StatusCode, nil)
+	middleware.SetUpstream(c, githubProvider, "")
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Println("请求 Chat 编辑接口失败:", string(body))
+		slog.ErrorContext(ctx, "请求 Chat 编辑接口失败", "status", resp.StatusCode, "body", string(body))
 
 		abortCodex(c, resp.StatusCode)
 		return
@@ -221,40 +232,46 @@ func ChatEditCompletions(c *gin.Context) {
 
 	c.Status(resp.StatusCode)
 	c.Header("Content-Type", resp.Header.Get("Content-Type"))
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
 	_, _ = io.Copy(c.Writer, resp.Body)
 }
 
 // getAuthToken 获取GitHub Copilot的临时Token
-func getAuthToken() (string, error) {
-	ghuTokens := strings.Split(os.Getenv("COPILOT_GHU_TOKEN"), ",")
-	if len(ghuTokens) == 0 {
-		return "", fmt.Errorf("COPILOT_GHU_TOKEN environment variable is empty or malformed")
+func getAuthToken(ctx context.Context, cfg *config.Config) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "getAuthToken")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	pool := keypool.Get("ghu", cfg.Copilot.GHUTokens, "")
+	key, err := pool.Acquire()
+	if err != nil {
+		return "", fmt.Errorf("COPILOT_GHU_TOKEN is unavailable: %w", err)
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	ghu := ghuTokens[rand.Intn(len(ghuTokens))]
+	ghu := key.Value()
 	cacheKey := "github:copilot_internal_v2_token:" + ghu
-	token, err := cache.Get(cacheKey)
+	token, ok, err := cache.Get[string](ctx, cacheKey)
+	span.SetAttr("cache_hit", ok)
 	if err != nil {
-		cache.Del(cacheKey)
+		pool.Report(ctx, key, 0)
+		cache.Del(ctx, cacheKey)
 		return "", err
 	}
-	if token != nil {
-		return token.(string), nil
+	if ok {
+		pool.Report(ctx, key, 0)
+		return token, nil
 	}
 
 	url := "https://api.github.com/copilot_internal/v2/token"
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	client, err := githubClient(cfg)
+	if err != nil {
+		pool.Report(ctx, key, 0)
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
+		pool.Report(ctx, key, 0)
 		return "", err
 	}
 
@@ -268,12 +285,14 @@ func getAuthToken() (string, error) {
 
 	res, err := client.Do(req)
 	if err != nil {
+		pool.Report(ctx, key, 0)
 		return "", err
 	}
 	defer res.Body.Close()
+	pool.Report(ctx, key, res.StatusCode)
 
 	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("获取 Token 失败" + ghu)
+		return "", fmt.Errorf("获取 Token 失败, status: %d", res.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(res.Body)
@@ -288,7 +307,7 @@ func getAuthToken() (string, error) {
 	}
 
 	newToken := result["token"].(string)
-	err = cache.Set(cacheKey, newToken, 1500)
+	err = cache.Set(ctx, cacheKey, newToken, 1500)
 	if err != nil {
 		return "", err
 	}
@@ -296,7 +315,7 @@ func getAuthToken() (string, error) {
 }
 
 // mergeHeaders 合并请求头，固定请求头会覆盖原有请求头
-func mergeHeaders(originalHeader http.Header, req *http.Request) error {
+func mergeHeaders(cfg *config.Config, originalHeader http.Header, req *http.Request) error {
 	// 复制原始请求头
 	for key, values := range originalHeader {
 		for _, value := range values {
@@ -305,7 +324,7 @@ func mergeHeaders(originalHeader http.Header, req *http.Request) error {
 	}
 
 	// 获取token
-	token, err := getAuthToken()
+	token, err := getAuthToken(req.Context(), cfg)
 	if err != nil {
 		return fmt.Errorf("获取GitHub Copilot的临时Token失败: %w", err)
 	}
@@ -327,29 +346,34 @@ func mergeHeaders(originalHeader http.Header, req *http.Request) error {
 	return nil
 }
 
+// githubClient 获取 GitHub Copilot 共享的 http 客户端
+func githubClient(cfg *config.Config) (*http.Client, error) {
+	return httpclient.Get(githubProvider, httpclient.FromConfig(cfg.Upstream))
+}
+
 // GetCopilotModels 获取GitHub Copilot的模型列表
 func GetCopilotModels(c *gin.Context) {
-	copilotAccountType := os.Getenv("COPILOT_ACCOUNT_TYPE")
+	cfg := config.FromContext(c)
+	copilotAccountType := cfg.Copilot.AccountType
 	url := "https://api." + copilotAccountType + ".githubcopilot.com/models"
-	req, err := http.NewRequestWithContext(c, "GET", url, nil)
+	req, err := http.NewRequestWithContext(c.Request.Context(), "GET", url, nil)
 	if nil != err {
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
 	// 合并请求头
-	if err := mergeHeaders(c.Request.Header, req); err != nil {
-		log.Println(err)
+	if err := mergeHeaders(cfg, c.Request.Header, req); err != nil {
+		slog.ErrorContext(c.Request.Context(), "merge headers failed", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
 
-	httpClientTimeout, _ := time.ParseDuration(os.Getenv("HTTP_CLIENT_TIMEOUT") + "s")
-	client := &http.Client{
-		Timeout: httpClientTimeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	client, err := githubClient(cfg)
+	if nil != err {
+		slog.ErrorContext(c.Request.Context(), "create github client failed", "error", err)
+		abortCodex(c, http.StatusInternalServerError)
+		return
 	}
 	resp, err := client.Do(req)
 	if nil != err {
@@ -358,7 +382,7 @@ func GetCopilotModels(c *gin.Context) {
 			return
 		}
 
-		log.Println("获取模型列表失败:", err.Error())
+		slog.ErrorContext(c.Request.Context(), "获取模型列表失败", "error", err)
 		abortCodex(c, http.StatusInternalServerError)
 		return
 	}
@@ -366,7 +390,7 @@ func GetCopilotModels(c *gin.Context) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Println("请求GitHub Copilot模型列表失败:", string(body))
+		slog.ErrorContext(c.Request.Context(), "请求GitHub Copilot模型列表失败", "status", resp.StatusCode, "body", string(body))
 
 		abortCodex(c, resp.StatusCode)
 		return
@@ -375,7 +399,5 @@ func GetCopilotModels(c *gin.Context) {
 	// 转发原始响应
 	c.Status(resp.StatusCode)
 	c.Header("Content-Type", resp.Header.Get("Content-Type"))
-	requestID := uuid.Must(uuid.NewV4()).String()
-	c.Header("x-github-request-id", requestID)
 	_, _ = io.Copy(c.Writer, resp.Body)
 }
@@ -1,56 +1,50 @@
 package copilot
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
-	"os"
+	"ripper/internal/app/fim"
+	"ripper/internal/app/models"
+	"ripper/internal/app/provider"
+	"ripper/internal/app/ratelimit"
+	"ripper/internal/config"
 	"ripper/internal/middleware"
-	"strconv"
 )
 
-type Config struct {
-	ClientType      string
-	CopilotProxyAll bool
-}
-
-// loadConfig loads the configuration from environment variables.
-func loadConfig() (*Config, error) {
-	proxyAll, err := strconv.ParseBool(os.Getenv("COPILOT_PROXY_ALL"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid boolean value for COPILOT_PROXY_ALL: %v", err)
+// GinApi 注册路由
+func GinApi(g *gin.RouterGroup, cfg *config.Config) {
+	// 初始化多模型上游路由
+	if err := provider.Init(cfg); err != nil {
+		log.Fatal(err)
 	}
 
-	return &Config{
-		ClientType:      os.Getenv("COPILOT_CLIENT_TYPE"),
-		CopilotProxyAll: proxyAll,
-	}, nil
-}
+	// 初始化代码补全 FIM 模板
+	if err := fim.Init(cfg.Codex.FIMTemplatesFile); err != nil {
+		log.Fatal(err)
+	}
 
-// GinApi 注册路由
-func GinApi(g *gin.RouterGroup) {
-	config, err := loadConfig()
-	if err != nil {
+	// 初始化模型列表
+	if err := models.Init(cfg.Chat.ModelsFile); err != nil {
 		log.Fatal(err)
 	}
 
 	// 基础路由
-	setupBasicRoutes(g, config)
+	setupBasicRoutes(g)
 
 	// 用户相关路由
 	setupUserRoutes(g)
 
 	// Copilot相关路由
-	setupCopilotRoutes(g, config)
+	setupCopilotRoutes(g)
 
 	// API v3相关路由
 	setupV3Routes(g)
 }
 
 // setupBasicRoutes 设置基础路由
-func setupBasicRoutes(g *gin.RouterGroup, config *Config) {
-	g.Any("/models", createModelsHandler(config))
-	g.Any("/models/session", createModelsHandler(config))
+func setupBasicRoutes(g *gin.RouterGroup) {
+	g.Any("/models", createModelsHandler())
+	g.Any("/models/session", createModelsHandler())
 	g.Any("/_ping", GetPing)
 	g.POST("/telemetry", PostTelemetry)
 	g.Any("/agents", GetAgents)
@@ -70,30 +64,37 @@ func setupUserRoutes(g *gin.RouterGroup) {
 		userGroup.GET("/api/v3/user", GetLoginUser)
 		userGroup.GET("/api/v3/user/orgs", GetUserOrgs)
 		userGroup.GET("/teams/:teamID/memberships/:username", GetMembership)
-		userGroup.POST("/chunks", HandleChunks)
+		userGroup.POST("/chunks", middleware.Metrics("HandleChunks"), middleware.Capture("HandleChunks"), middleware.RateLimit(ratelimit.Embeddings), middleware.Usage(), HandleChunks)
 	}
 }
 
 // setupCopilotRoutes 设置Copilot相关路由
-func setupCopilotRoutes(g *gin.RouterGroup, config *Config) {
+func setupCopilotRoutes(g *gin.RouterGroup) {
 	tokenMiddleware := middleware.TokenCheckAuth()
 
 	// Copilot token endpoint
 	g.GET("/copilot_internal/v2/token",
 		middleware.AccessTokenCheckAuth(),
-		createTokenHandler(config))
+		createTokenHandler())
 
 	// Completions endpoints
 	completionsGroup := g.Group("")
 	completionsGroup.Use(tokenMiddleware)
 	{
-		completionsGroup.POST("/v1/engines/:model-name/completions", createCompletionsHandler(config))
-		completionsGroup.POST("/v1/engines/copilot-codex", createCompletionsHandler(config))
-		completionsGroup.POST("/chat/completions", createChatHandler(config))
-		completionsGroup.POST("/agents/chat", createChatHandler(config))
-		completionsGroup.POST("/v1/chat/completions", createChatHandler(config))
-		completionsGroup.POST("/v1/engines/copilot-centralus-h100/speculation", createChatEditCompletionsHandler(config))
-		completionsGroup.POST("/embeddings", HandleEmbeddings)
+		completionsLimit := middleware.RateLimit(ratelimit.Completions)
+		chatLimit := middleware.RateLimit(ratelimit.Chat)
+		usage := middleware.Usage()
+		completionsMetrics := middleware.Metrics("CodeCompletions")
+		chatMetrics := middleware.Metrics("ChatCompletions")
+		completionsCapture := middleware.Capture("CodeCompletions")
+		chatCapture := middleware.Capture("ChatCompletions")
+		completionsGroup.POST("/v1/engines/:model-name/completions", completionsMetrics, completionsCapture, completionsLimit, usage, createCompletionsHandler())
+		completionsGroup.POST("/v1/engines/copilot-codex", completionsMetrics, completionsCapture, completionsLimit, usage, createCompletionsHandler())
+		completionsGroup.POST("/chat/completions", chatMetrics, chatCapture, chatLimit, usage, createChatHandler())
+		completionsGroup.POST("/agents/chat", chatMetrics, chatCapture, chatLimit, usage, createChatHandler())
+		completionsGroup.POST("/v1/chat/completions", chatMetrics, chatCapture, chatLimit, usage, createChatHandler())
+		completionsGroup.POST("/v1/engines/copilot-centralus-h100/speculation", middleware.Metrics("ChatEditCompletions"), middleware.Capture("ChatEditCompletions"), chatLimit, usage, createChatEditCompletionsHandler())
+		completionsGroup.POST("/embeddings", middleware.Metrics("HandleEmbeddings"), middleware.Capture("HandleEmbeddings"), middleware.RateLimit(ratelimit.Embeddings), usage, HandleEmbeddings)
 	}
 }
 
@@ -105,9 +106,10 @@ func setupV3Routes(g *gin.RouterGroup) {
 }
 
 // 处理函数生成器
-func createTokenHandler(config *Config) gin.HandlerFunc {
+func createTokenHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.ClientType == "github" && !config.CopilotProxyAll {
+		cfg := config.FromContext(c)
+		if cfg.IsGithub() && !cfg.ProxyAll() {
 			GetCopilotInternalV2Token(c)
 		} else {
 			GetDisguiseCopilotInternalV2Token(c)
@@ -116,9 +118,10 @@ func createTokenHandler(config *Config) gin.HandlerFunc {
 }
 
 // createCompletionsHandler 生成代码补全处理函数
-func createCompletionsHandler(config *Config) gin.HandlerFunc {
+func createCompletionsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.ClientType == "github" && config.CopilotProxyAll {
+		cfg := config.FromContext(c)
+		if cfg.ProxyAll() {
 			CodexCompletions(c)
 		} else {
 			CodeCompletions(c)
@@ -127,9 +130,10 @@ func createCompletionsHandler(config *Config) gin.HandlerFunc {
 }
 
 // createChatHandler 生成聊天补全处理函数
-func createChatHandler(config *Config) gin.HandlerFunc {
+func createChatHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.ClientType == "github" && config.CopilotProxyAll {
+		cfg := config.FromContext(c)
+		if cfg.ProxyAll() {
 			ChatsCompletions(c)
 		} else {
 			ChatCompletions(c)
@@ -138,9 +142,10 @@ func createChatHandler(config *Config) gin.HandlerFunc {
 }
 
 // createChatEditCompletionsHandler 生成聊天编辑补全处理函数
-func createChatEditCompletionsHandler(config *Config) gin.HandlerFunc {
+func createChatEditCompletionsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.ClientType == "github" && config.CopilotProxyAll {
+		cfg := config.FromContext(c)
+		if cfg.ProxyAll() {
 			ChatEditCompletions(c)
 		} else {
 			CodeCompletions(c)
@@ -149,9 +154,10 @@ func createChatEditCompletionsHandler(config *Config) gin.HandlerFunc {
 }
 
 // createModelsHandler 生成模型处理函数
-func createModelsHandler(config *Config) gin.HandlerFunc {
+func createModelsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.ClientType == "github" && config.CopilotProxyAll {
+		cfg := config.FromContext(c)
+		if cfg.ProxyAll() {
 			GetCopilotModels(c)
 		} else {
 			GetModels(c)
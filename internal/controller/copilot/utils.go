@@ -2,16 +2,13 @@ package copilot
 
 import (
 	_ "embed"
-	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"ripper/internal/app/models"
+	"ripper/internal/config"
 	"time"
 
-	"github.com/gofrs/uuid"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,9 +20,6 @@ type Pong struct {
 
 // GetPing 模拟ping接口
 func GetPing(ctx *gin.Context) {
-	requestID := uuid.Must(uuid.NewV4()).String()
-	ctx.Header("x-github-request-id", requestID)
-
 	ctx.JSON(http.StatusOK, Pong{
 		Now:    time.Now().Second(),
 		Status: "ok",
@@ -42,39 +36,23 @@ type ModelsResponse struct {
 
 // GetModels 获取模型列表
 func GetModels(ctx *gin.Context) {
-	// 从根目录下读取models.json文件
-	jsonFile, err := os.Open(filepath.Join("models.json"))
-	if err != nil {
-		log.Printf("无法打开models.json文件: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法读取模型列表数据"})
-		return
-	}
-	defer CloseIO(jsonFile)
-
-	// 解析JSON数据
-	jsonData, err := io.ReadAll(jsonFile)
-	if err != nil {
-		log.Printf("读取models.json内容失败: %v", err)
+	// models.json 在启动及热加载时读取
+	data, ok := models.List()
+	if !ok {
+		slog.WarnContext(ctx.Request.Context(), "未加载模型列表文件", "path", config.FromContext(ctx).Chat.ModelsFile)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法读取模型列表数据"})
 		return
 	}
 
-	var modelsResponse ModelsResponse
-	if err := json.Unmarshal(jsonData, &modelsResponse); err != nil {
-		log.Printf("解析models.json失败: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法解析模型列表数据"})
-		return
-	}
+	modelsResponse := ModelsResponse{Data: data, Object: "list"}
 	modelsResponse.Expires_At = time.Now().Add(1 * time.Hour).Unix()
 	// 返回模型列表数据
-	requestID := uuid.Must(uuid.NewV4()).String()
-	ctx.Header("x-github-request-id", requestID)
 	ctx.JSON(http.StatusOK, modelsResponse)
 }
 
 func CloseIO(c io.Closer) {
 	err := c.Close()
 	if nil != err {
-		log.Println(err)
+		slog.Warn("close failed", "error", err)
 	}
 }
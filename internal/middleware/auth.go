@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
-	"os"
 	"ripper/internal/app/github_auth"
+	"ripper/internal/app/session"
+	"ripper/internal/app/users"
+	"ripper/internal/config"
 	"ripper/internal/response"
 	jwtpkg "ripper/pkg/jwt"
 	"strconv"
@@ -30,8 +33,8 @@ func DeviceCodeCheckAuth(ctx *gin.Context) {
 		ctx.Abort()
 		return
 	}
-	info, _ := github_auth.GetClientAuthInfoByDeviceCode(checkInfo.DeviceCode)
-	if info.CardCode == "" {
+	info, err := github_auth.GetClientAuthInfoByDeviceCode(ctx, checkInfo.DeviceCode)
+	if err != nil || info.CardCode == "" {
 		ctx.JSON(http.StatusOK, gin.H{
 			"error":             "authorization_pending",
 			"error_description": "The authorization request is still pending.",
@@ -55,7 +58,7 @@ func AuthCodeFlowCheckAuth(ctx *gin.Context) {
 		ctx.Abort()
 		return
 	}
-	oauthCodeInfo, err := github_auth.GetOAuthCodeInfoByClientIdAndCode(checkInfoClient.ClientId, checkInfoClient.Code)
+	oauthCodeInfo, err := github_auth.GetOAuthCodeInfoByClientIdAndCode(ctx, checkInfoClient.ClientId, checkInfoClient.Code)
 	if err != nil {
 		response.FailJson(ctx, response.FailStruct{
 			Code: -1,
@@ -84,6 +87,22 @@ func AccessTokenCheckAuth() gin.HandlerFunc {
 			return
 		}
 		token = token[last+1:]
+		// 用户模式下允许直接使用访问令牌
+		if users.Enabled() && users.IsToken(token) {
+			user, accessToken, err := users.Default().Authenticate(token)
+			if err != nil {
+				errmsg := response.TokenWrongful
+				errmsg.Msg = "访问令牌无效"
+				response.FailJsonAndStatusCode(c, http.StatusForbidden, errmsg, true, err.Error())
+				c.Abort()
+				return
+			}
+			c.Set("token", newUserLoadFromAccessToken(user, accessToken))
+			c.Set("tokenStr", token)
+			c.Set("token.issuer", "user")
+			c.Next()
+			return
+		}
 		chk, jwter, err := jwtpkg.CheckToken(token, &UserLoad{}, "user")
 		if err != nil {
 			errmsg := response.TokenWrongful
@@ -117,6 +136,26 @@ func AccessTokenCheckAuth() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// 用户模式下 JWT 必须由仍然有效的访问令牌换取
+		if users.Enabled() {
+			if _, _, err := users.Default().Check(jwter.TokenID); jwter.TokenID == "" || err != nil {
+				errmsg := response.TokenWrongful
+				errmsg.Msg = "访问令牌无效"
+				response.FailJsonAndStatusCode(c, http.StatusForbidden, errmsg, false)
+				c.Abort()
+				return
+			}
+		}
+		// 会话已被管理员撤销
+		if jwter.ID != "" {
+			if revoked, err := session.IsRevoked(jwter.ID); revoked || err != nil {
+				errmsg := response.TokenWrongful
+				errmsg.Msg = "令牌已撤销"
+				response.FailJsonAndStatusCode(c, http.StatusForbidden, errmsg, false)
+				c.Abort()
+				return
+			}
+		}
 		c.Set("token", jwter)
 		c.Set("tokenStr", token)
 		c.Set("token.issuer", issuerStr)
@@ -124,11 +163,32 @@ func AccessTokenCheckAuth() gin.HandlerFunc {
 	}
 }
 
+// AdminCheckAuth 管理接口鉴权, 请求头需要携带 Authorization: Bearer <ADMIN_TOKEN>
+// 未设置 ADMIN_TOKEN 时禁用所有管理接口
+func AdminCheckAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := config.FromContext(c).Auth.AdminToken
+		if adminToken == "" {
+			response.FailJsonAndStatusCode(c, http.StatusForbidden, response.NoAccess, false)
+			c.Abort()
+			return
+		}
+		token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.NoAccess, false)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// TokenCheckAuth 校验补全请求携带的 Copilot token
+// token 由 GetDisguiseCopilotInternalV2Token 签发, 校验过期时间和 8kp 签名, 失败时返回 401 使插件重新获取 token
 func TokenCheckAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientType := os.Getenv("COPILOT_CLIENT_TYPE")
-		copilotProxyAll, err := strconv.ParseBool(os.Getenv("COPILOT_PROXY_ALL"))
-		if clientType == "github" && !copilotProxyAll {
+		cfg := config.FromContext(c)
+		if cfg.IsGithub() && !cfg.ProxyAll() {
 			c.Next()
 			return
 		}
@@ -145,48 +205,78 @@ func TokenCheckAuth() gin.HandlerFunc {
 			return
 		}
 		token = token[last+1:]
-		if err != nil {
+		// 用户模式下允许直接使用访问令牌
+		if users.Enabled() && users.IsToken(token) {
+			user, _, err := users.Default().Authenticate(token)
+			if err != nil {
+				response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, true, err.Error())
+				c.Abort()
+				return
+			}
+			c.Set("user_id", user.ID)
+			c.Next()
+			return
+		}
 
+		parsedToken := parseAuthorizationToken(token)
+		expired, err := isExpired(parsedToken["exp"])
+		if err != nil {
+			response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, true, err.Error())
+			c.Abort()
+			return
+		}
+		if expired {
+			response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenOverdue, false)
+			c.Abort()
+			return
+		}
+		if !checkTokenSign(parsedToken) {
+			response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, false)
+			c.Abort()
+			return
+		}
+		// 用户模式下 token 必须由仍然有效的访问令牌换取
+		if users.Enabled() {
+			tokenID := parsedToken["tkid"]
+			if _, _, err := users.Default().Check(tokenID); tokenID == "" || err != nil {
+				response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, false)
+				c.Abort()
+				return
+			}
+		}
+		// 换取该 token 的登录会话已被撤销
+		if sid := parsedToken["sid"]; sid != "" {
+			if revoked, err := session.IsRevoked(sid); revoked || err != nil {
+				response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, false)
+				c.Abort()
+				return
+			}
+		}
+		c.Set("copilot_token", parsedToken)
+		if uid := parsedToken["uid"]; uid != "" {
+			c.Set("user_id", uid)
 		}
-		// parsedToken := parseAuthorizationToken(token)
-		// log.Println("parsedToken type: %T, value: %+v\n", parsedToken)
-		// log.Println("exp", parsedToken["exp"], reflect.TypeOf(parsedToken["exp"]))
-		// 校验exp是否过期
-		// expired, err := isExpired(parsedToken["exp"])
-		// if err != nil {
-		// 	fmt.Sprintf("return1")
-		// 	log.Println("return1", err.Error())
-		// 	response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, false)
-		// 	c.Abort()
-		// 	return
-		// } else {
-		// 	if expired {
-		// 		fmt.Sprintf("return2")
-		// 		log.Println("return2")
-		// 		response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenOverdue, false)
-		// 		c.Abort()
-		// 		return
-		// 	}
-		// }
-		// 貌似是token验证失败,反正是我自己用,先注释
-		// rawToken := github_auth.JsonMap2Token(map[string]interface{}{
-		// 	"tid":  parsedToken["tid"],
-		// 	"exp":  parsedToken["exp"],
-		// 	"sku":  parsedToken["sku"],
-		// 	"st":   parsedToken["st"],
-		// 	"chat": parsedToken["chat"],
-		// 	"u":    parsedToken["u"],
-		// })
-		// sign := "1:" + github_auth.Token2Sign(rawToken)
-		// if sign != parsedToken["8kp"] {
-		// 	response.FailJsonAndStatusCode(c, http.StatusUnauthorized, response.TokenWrongful, false)
-		// 	c.Abort()
-		// 	return
-		// }
 		c.Next()
 	}
 }
 
+// checkTokenSign 校验 8kp 签名, 签名覆盖除 8kp 以外的所有字段
+func checkTokenSign(parsedToken map[string]string) bool {
+	sign, ok := parsedToken["8kp"]
+	if !ok {
+		return false
+	}
+	fields := make(map[string]interface{}, len(parsedToken))
+	for k, v := range parsedToken {
+		if k != "8kp" {
+			fields[k] = v
+		}
+	}
+	expected := "1:" + github_auth.Token2Sign(github_auth.JsonMap2Token(fields))
+	return subtle.ConstantTimeCompare([]byte(sign), []byte(expected)) == 1
+}
+
+// parseAuthorizationToken 解析 Copilot token 中的字段, 只保留签发时写入的字段
 func parseAuthorizationToken(token string) map[string]string {
 	result := make(map[string]string)
 	pairs := strings.Split(token, ";")
@@ -197,7 +287,7 @@ func parseAuthorizationToken(token string) map[string]string {
 			key := kv[0]
 			value := kv[1]
 
-			if key == "tid" || key == "exp" || key == "sku" || key == "st" || key == "8kp" || key == "chat" || key == "u" {
+			if key == "tid" || key == "exp" || key == "sku" || key == "st" || key == "8kp" || key == "chat" || key == "u" || key == "uid" || key == "tkid" || key == "sid" {
 				result[key] = value
 			}
 		}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"ripper/internal/app/github_auth"
+	"ripper/internal/config"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// signWithSalt 使用指定的 salt 签发 Copilot token
+func signWithSalt(fields map[string]interface{}, salt string) string {
+	token := github_auth.JsonMap2Token(fields)
+	hash := sha256.Sum256([]byte(token + ";salt=" + salt))
+	return token + ";8kp=1:" + hex.EncodeToString(hash[:])
+}
+
+func TestTokenCheckAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Auth.TokenSalt = "test-salt"
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	fields := func(exp string) map[string]interface{} {
+		return map[string]interface{}{"tid": "t1", "exp": exp, "sku": "free", "uid": "u1"}
+	}
+	valid := github_auth.JsonMap2SignToken(fields(future))
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"forged field", strings.Replace(valid, "uid=u1", "uid=u2", 1), http.StatusUnauthorized},
+		{"forged sign", valid[:len(valid)-4] + "0000", http.StatusUnauthorized},
+		{"expired", github_auth.JsonMap2SignToken(fields(past)), http.StatusUnauthorized},
+		{"wrong salt", signWithSalt(fields(future), "other-salt"), http.StatusUnauthorized},
+		{"missing 8kp", github_auth.JsonMap2Token(fields(future)), http.StatusUnauthorized},
+		{"invalid exp", github_auth.JsonMap2SignToken(fields("soon")), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", TokenCheckAuth(), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("user_id"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "token "+tt.token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK && w.Body.String() != "u1" {
+				t.Fatalf("user_id = %q, want u1", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"ripper/internal/app/capture"
+	"ripper/internal/config"
+	"ripper/pkg/logs"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureWriter 将写给插件的响应同时记录下来, 超过 capture.MaxBodySize 的部分丢弃
+type captureWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.record(p[:n])
+	return n, err
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.record([]byte(s[:n]))
+	return n, err
+}
+
+func (w *captureWriter) record(p []byte) {
+	if remain := capture.MaxBodySize - w.buf.Len(); len(p) > remain {
+		p = p[:remain]
+		w.truncated = true
+	}
+	w.buf.Write(p)
+}
+
+// Capture 启用请求捕获时, 将请求体、脱敏后的请求头和完整响应按用户和路由追加到 JSONL 归档中, 供 ripper replay 重放
+// route 为归档文件名中的路由, 需要放在鉴权之后以识别用户
+func Capture(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.FromContext(c).Capture
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		user := userKey(c)
+		if len(cfg.Users) > 0 && !slices.Contains(cfg.Users, user) {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		truncated := false
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		record := &capture.Record{
+			ID:     logs.RequestID(c.Request.Context()),
+			Time:   time.Now(),
+			User:   user,
+			Route:  route,
+			Method: c.Request.Method,
+			Path:   c.Request.URL.RequestURI(),
+			Header: capture.RedactHeader(c.Request.Header),
+		}
+		if len(body) > capture.MaxBodySize {
+			body, truncated = body[:capture.MaxBodySize], true
+		}
+		record.Body = string(body)
+
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		record.Provider, record.Model = c.GetString(upstreamProviderKey), c.GetString(upstreamModelKey)
+		record.Status = writer.Status()
+		record.ResponseHeader = capture.RedactHeader(writer.Header())
+		record.Response = writer.buf.String()
+		record.Truncated = truncated || writer.truncated
+		record.DurationMs = time.Since(record.Time).Milliseconds()
+
+		ctx := c.Request.Context()
+		go func() {
+			if err := capture.Save(cfg.Dir, record); err != nil {
+				slog.WarnContext(ctx, "failed to save capture", "error", err)
+			}
+		}()
+	}
+}
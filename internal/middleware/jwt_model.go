@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/golang-jwt/jwt/v5"
+	"ripper/internal/app/users"
 	jwtPkg "ripper/pkg/jwt"
 )
 
@@ -13,9 +14,24 @@ type UserLoad struct {
 	UserDisplayName string `json:"userDisplayName,omitempty"`
 	CardCode        string `json:"token"`
 	Client          string `json:"client"`
+	UserID          string `json:"uid,omitempty"`  // 用户模式下的用户 ID
+	TokenID         string `json:"tkid,omitempty"` // 用户模式下换取该令牌的访问令牌 ID
 	jwt.RegisteredClaims
 }
 
+// newUserLoadFromAccessToken 直接使用访问令牌时构造用户负载, 便于后续接口读取用户信息
+func newUserLoadFromAccessToken(user *users.User, token *users.Token) *UserLoad {
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Name
+	}
+	return &UserLoad{
+		UserDisplayName: displayName,
+		UserID:          user.ID,
+		TokenID:         token.ID,
+	}
+}
+
 func NewUserLoad(ID uint, ExpiresAt int64, Issuer string) *UserLoad {
 	return &UserLoad{
 		RegisteredClaims: jwtPkg.CreateStandardClaims(ExpiresAt, Issuer),
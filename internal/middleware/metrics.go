@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"ripper/internal/app/metrics"
+	"ripper/internal/app/usage"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	upstreamProviderKey = "upstream_provider"
+	upstreamModelKey    = "upstream_model"
+	debounceKey         = "debounce_cancelled"
+)
+
+// SetUpstream 记录本次请求实际使用的上游提供方和模型, 用于监控指标的标签
+func SetUpstream(c *gin.Context, provider string, model string) {
+	c.Set(upstreamProviderKey, provider)
+	c.Set(upstreamModelKey, model)
+}
+
+// MarkDebounceCancelled 标记请求在补全防抖期间被插件取消
+func MarkDebounceCancelled(c *gin.Context) {
+	c.Set(debounceKey, true)
+}
+
+// metricsWriter 记录流式响应首次写入的时间
+type metricsWriter struct {
+	gin.ResponseWriter
+	firstByte time.Time
+}
+
+func (w *metricsWriter) Write(p []byte) (int, error) {
+	w.markFirstByte()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *metricsWriter) WriteString(s string) (int, error) {
+	w.markFirstByte()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *metricsWriter) markFirstByte() {
+	if w.firstByte.IsZero() {
+		w.firstByte = time.Now()
+	}
+}
+
+// Metrics 记录请求数、耗时、流式响应首字节时间、防抖取消次数和 token 用量
+// route 为监控指标中的 route 标签, 需要放在 RateLimit 和 Usage 之前
+func Metrics(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		writer := &metricsWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if c.GetBool(debounceKey) {
+			metrics.DebounceCancelled.Inc(route)
+		}
+
+		provider, model := c.GetString(upstreamProviderKey), c.GetString(upstreamModelKey)
+		value, _ := c.Get(UsageResultKey)
+		result, hasUsage := value.(usage.Result)
+		if model == "" && hasUsage {
+			model = result.Model
+		}
+
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.Requests.Inc(route, provider, model, status)
+		metrics.RequestDuration.Observe(time.Since(start).Seconds(), route, provider, model)
+		if !writer.firstByte.IsZero() && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+			metrics.StreamFirstByte.Observe(writer.firstByte.Sub(start).Seconds(), route, provider, model)
+		}
+
+		if hasUsage {
+			metrics.Tokens.Add(float64(result.PromptTokens), route, provider, model, "prompt")
+			metrics.Tokens.Add(float64(result.CompletionTokens), route, provider, model, "completion")
+			if result.CachedTokens > 0 {
+				metrics.Tokens.Add(float64(result.CachedTokens), route, provider, model, "cached")
+			}
+		}
+	}
+}
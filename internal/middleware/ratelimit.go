@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"ripper/internal/app/ratelimit"
+	"ripper/internal/config"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 按用户限制每分钟请求数和每天 token 数, 超出时返回 429 及 Retry-After
+// category 为 completions、chat 或 embeddings, 需要放在 TokenCheckAuth 之后, Usage 之前
+func RateLimit(category string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rpm, tpd := config.FromContext(c).RateLimit.Limits(category)
+		if rpm == 0 && tpd == 0 {
+			c.Next()
+			return
+		}
+
+		key := userKey(c)
+		retryAfter, err := ratelimit.Check(c, category, key, rpm, tpd)
+		if err != nil {
+			// 缓存不可用时不影响正常请求
+			slog.WarnContext(c.Request.Context(), "rate limit check failed", "error", err)
+			c.Next()
+			return
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": gin.H{
+					"message": "Sorry, you have been rate-limited. Please wait a moment before trying again.",
+					"code":    "rate_limited",
+				},
+			})
+			return
+		}
+
+		c.Next()
+
+		if tpd == 0 {
+			return
+		}
+		tokens := c.GetInt(UsageTokensKey)
+		if err := ratelimit.AddTokens(c, category, key, tokens); err != nil {
+			slog.WarnContext(c.Request.Context(), "failed to record token usage", "error", err)
+		}
+	}
+}
+
+// userKey 用于限流和用量统计的用户标识, 依次使用用户 ID、登录会话、Copilot token 的 tid, 都不存在时使用客户端 IP
+func userKey(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return "user:" + userID
+	}
+	if load, ok := c.Get("token"); ok {
+		if load, ok := load.(*UserLoad); ok && load.ID != "" {
+			return "session:" + load.ID
+		}
+	}
+	if parsedToken, ok := c.Get("copilot_token"); ok {
+		parsedToken := parsedToken.(map[string]string)
+		if sid := parsedToken["sid"]; sid != "" {
+			return "session:" + sid
+		}
+		if tid := parsedToken["tid"]; tid != "" {
+			return "tid:" + tid
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"ripper/internal/app/tracing"
+	"ripper/pkg/logs"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Trace 为每个请求创建链路追踪的根 span, 处理函数通过 c.Request.Context() 创建子 span
+// 需要放在 RequestID 之后, 未匹配路由的请求不记录
+func Trace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+
+		ctx, span := tracing.StartAt(c.Request.Context(), c.Request.Method+" "+route, tracing.KindServer, time.Now())
+		c.Request = c.Request.WithContext(ctx)
+		defer span.End()
+		span.SetAttr("http.method", c.Request.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("request_id", logs.RequestID(ctx))
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttr("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+		}
+		span.SetAttr("user", userKey(c))
+		if provider := c.GetString(upstreamProviderKey); provider != "" {
+			span.SetAttr("upstream.provider", provider)
+			span.SetAttr("upstream.model", c.GetString(upstreamModelKey))
+		}
+		if c.GetBool(debounceKey) {
+			span.SetAttr("debounce_cancelled", true)
+		}
+		if tokens := c.GetInt(UsageTokensKey); tokens > 0 {
+			span.SetAttr("usage.tokens", tokens)
+		}
+	}
+}
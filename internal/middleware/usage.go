@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"ripper/internal/app/usage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// UsageTokensKey 请求上下文中保存本次请求使用的 token 数的键, 由 Usage 设置
+	UsageTokensKey = "usage_tokens"
+	// UsageResultKey 请求上下文中保存本次请求 usage.Result 的键, 由 Usage 设置
+	UsageResultKey = "usage_result"
+)
+
+// usageWriter 将写给插件的响应同时交给 Meter 解析
+type usageWriter struct {
+	gin.ResponseWriter
+	meter *usage.Meter
+}
+
+func (w *usageWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.meter.Write(p[:n])
+	return n, err
+}
+
+func (w *usageWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.meter.Write([]byte(s[:n]))
+	return n, err
+}
+
+// ObserveUsage 实现 provider.UsageObserver, 统计未转发给插件的 usage chunk
+func (w *usageWriter) ObserveUsage(chunk []byte) {
+	w.meter.Observe(chunk)
+}
+
+// Usage 从响应中解析 token 用量, 上游未返回 usage 时估算, 结果用于限流, 启用用量统计时按用户、模型、接口记录
+func Usage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		meter := &usage.Meter{}
+		c.Writer = &usageWriter{ResponseWriter: c.Writer, meter: meter}
+		c.Next()
+
+		// 上游请求失败时不计入用量
+		if c.Writer.Status() >= http.StatusBadRequest || c.Writer.Size() <= 0 {
+			return
+		}
+		result := meter.Finish(body)
+		c.Set(UsageTokensKey, result.Total())
+		c.Set(UsageResultKey, result)
+
+		if store := usage.Default(); store != nil {
+			store.AddAsync(c.Request.Context(), time.Now(), userKey(c), c.FullPath(), result)
+		}
+	}
+}
@@ -3,13 +3,17 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"html/template"
+	"ripper/internal/app/metrics"
+	"ripper/internal/config"
+	"ripper/internal/controller/admin"
 	authApi "ripper/internal/controller/auth"
 	"ripper/internal/controller/copilot"
 	"ripper/internal/middleware"
 	"ripper/static"
 )
 
-func NewHTTPRouter(r *gin.Engine) {
+// NewHTTPRouter 注册全部路由, cfg 会注入到每个请求的上下文中
+func NewHTTPRouter(r *gin.Engine, cfg *config.Config) {
 	rootRouter := r.Group("/")
 	tmpl := template.Must(template.New("").ParseFS(static.Public, "public/*.html"))
 	r.SetHTMLTemplate(tmpl)
@@ -19,7 +23,16 @@ func NewHTTPRouter(r *gin.Engine) {
 	rootRouter.Use(middleware.Cors())
 	apiRouter.Use(middleware.Cors())
 
+	rootRouter.Use(config.Inject())
+	apiRouter.Use(config.Inject())
+
 	authApi.GinApi(rootRouter)
-	copilot.GinApi(rootRouter)
+	copilot.GinApi(rootRouter, cfg)
+	admin.GinApi(rootRouter)
+
+	// 未设置独立指标端口时, 监控指标通过服务端口提供, 使用 ADMIN_TOKEN 鉴权
+	if cfg.Metrics.Enabled && cfg.Metrics.Port == 0 {
+		rootRouter.GET("/metrics", middleware.AdminCheckAuth(), gin.WrapH(metrics.Handler()))
+	}
 
 }
@@ -4,23 +4,32 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"ripper/pkg/certificate"
 	"ripper/pkg/message"
-	"strconv"
 	"syscall"
 	"time"
 
+	"ripper/internal/app/httpclient"
+	"ripper/internal/app/metrics"
+	"ripper/internal/app/provider"
+	"ripper/internal/app/reload"
+	"ripper/internal/app/tracing"
+	"ripper/internal/app/usage"
+	"ripper/internal/app/users"
+	"ripper/internal/cache"
+	"ripper/internal/config"
+	"ripper/internal/middleware"
 	"ripper/internal/router"
+	"ripper/pkg/jwt"
+	"ripper/pkg/logs"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -34,23 +43,88 @@ func checkPortAndExit(host string, port int) {
 	conn.Close()
 }
 
-func main() {
-	// 设置日志输出
-	setupLogging()
-
+// loadConfig 加载 .env 文件和配置文件, 返回配置及配置文件路径
+func loadConfig() (*config.Config, string) {
 	// 在非生产环境中加载 .env 文件
 	if os.Getenv("ENV") != "production" {
-		if err := godotenv.Load(); err != nil {
+		if err := reload.LoadEnvFile(".env"); err != nil {
 			log.Printf("Warning: Error loading .env file: %v", err)
 		}
 	}
 
-	log.Println("Current Environment: ", os.Getenv("ENV"))
+	// 加载配置文件, 环境变量优先级高于配置文件
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = "config.yaml"
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Set(cfg)
+	return cfg, configFile
+}
 
-	// 设置默认环境变量
-	initDefaultEnv()
+func main() {
+	// 重放捕获的请求
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replay(os.Args[2:])
+		return
+	}
+
+	cfg, configFile := loadConfig()
+	jwt.SetSigningKey(cfg.Auth.TokenSalt)
+
+	// 设置日志输出
+	logCloser, err := logs.Init(logs.Options{
+		Level:   cfg.Log.Level,
+		Format:  cfg.Log.Format,
+		Dir:     cfg.Log.Dir,
+		MaxSize: cfg.Log.MaxSize,
+		MaxAge:  cfg.Log.MaxAge,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logCloser.Close()
+	logs.SetSecrets(cfg.Secrets())
+	gin.DefaultWriter = logs.Writer(slog.LevelDebug)
+	gin.DefaultErrorWriter = logs.Writer(slog.LevelError)
 
-	r := gin.Default()
+	slog.Info("current environment", "env", cfg.Env)
+
+	// 初始化缓存
+	if err := cache.Init(cfg.Cache); err != nil {
+		log.Fatal(err)
+	}
+	defer cache.Close()
+
+	// 初始化用户存储
+	if err := users.Init(cfg.Auth); err != nil {
+		log.Fatal(err)
+	}
+	defer users.Close()
+
+	// 初始化用量统计
+	if err := usage.Init(cfg.Usage); err != nil {
+		log.Fatal(err)
+	}
+	defer usage.Close()
+
+	// 初始化链路追踪
+	exportOpts := httpclient.FromConfig(cfg.Upstream)
+	exportOpts.Untraced = true
+	exportClient, err := httpclient.Get("tracing", exportOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tracing.Init(cfg.Tracing, exportClient); err != nil {
+		log.Fatal(err)
+	}
+	defer tracing.Close()
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Trace(), middleware.Logger(), middleware.Recovery())
 	// 添加 HSTS 中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Strict-Transport-Security", "max-age=0")
@@ -58,12 +132,13 @@ func main() {
 	})
 
 	//初始化router
-	router.NewHTTPRouter(r)
+	router.NewHTTPRouter(r, cfg)
+	logs.SetSecrets(append(cfg.Secrets(), provider.Current().Secrets()...))
 
 	//获取配置
-	httpPort, _ := strconv.Atoi(os.Getenv("PORT"))
-	httpsPort, _ := strconv.Atoi(os.Getenv("HTTPS_PORT"))
-	host := os.Getenv("HOST")
+	httpPort := cfg.Server.Port
+	httpsPort := cfg.Server.HTTPSPort
+	host := cfg.Server.Host
 
 	// 初始化证书
 	certFile, keyFile, reloadChan, err := certificate.InitCertificates()
@@ -79,6 +154,9 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// 监听配置文件变化及 SIGHUP 信号, 无需重启即可生效
+	go reload.Watch(ctx, configFile)
+
 	// 创建一个错误组
 	g, groupCtx := errgroup.WithContext(ctx)
 
@@ -91,11 +169,27 @@ func main() {
 		Handler: r,
 	}
 	g.Go(func() error {
-		log.Printf("Starting HTTP server on %s\n", httpServer.Addr)
+		slog.Info("starting HTTP server", "addr", httpServer.Addr)
 		serverStarted <- struct{}{}
 		return httpServer.ListenAndServe()
 	})
 
+	// 启动独立的监控指标服务器
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Port != 0 {
+		checkPortAndExit(host, cfg.Metrics.Port)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", host, cfg.Metrics.Port),
+			Handler: mux,
+		}
+		g.Go(func() error {
+			slog.Info("starting metrics server", "addr", metricsServer.Addr)
+			return metricsServer.ListenAndServe()
+		})
+	}
+
 	// 创建一个函数来启动HTTPS服务器
 	var httpsServer *http.Server
 	startHTTPSServer := func() *http.Server {
@@ -111,7 +205,7 @@ func main() {
 		}
 
 		g.Go(func() error {
-			log.Printf("Starting HTTPS server on %s\n", server.Addr)
+			slog.Info("starting HTTPS server", "addr", server.Addr)
 			if httpsServer == nil { // 仅在首次启动时发送信号
 				serverStarted <- struct{}{}
 			}
@@ -140,14 +234,14 @@ func main() {
 		for {
 			select {
 			case <-reloadChan:
-				log.Println("Certificate update detected, reloading HTTPS server...")
+				slog.Info("certificate update detected, reloading HTTPS server")
 
 				// 创建关闭超时上下文
 				shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 
 				// 关闭当前的HTTPS服务器
 				if err := httpsServer.Shutdown(shutdownCtx); err != nil {
-					log.Printf("Error shutting down HTTPS server: %v", err)
+					slog.Error("error shutting down HTTPS server", "error", err)
 				}
 				shutdownCancel()
 
@@ -155,12 +249,12 @@ func main() {
 				httpsServer = startHTTPSServer()
 
 			case <-quit:
-				log.Println("Shutdown signal received, exiting...")
+				slog.Info("shutdown signal received, exiting")
 				cancel()
 				return
 
 			case <-groupCtx.Done():
-				log.Println("Unexpected exit, trying to shutdown gracefully...")
+				slog.Error("unexpected exit, trying to shutdown gracefully")
 				cancel()
 				return
 			}
@@ -176,68 +270,21 @@ func main() {
 
 	// 优雅地关闭服务器
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("HTTP server Shutdown: %v", err)
+		slog.Error("HTTP server shutdown failed", "error", err)
 	}
 
 	if err := httpsServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("HTTPS server Shutdown: %v", err)
-	}
-
-	// 等待所有 goroutine 完成
-	if err := g.Wait(); err != nil && err != http.ErrServerClosed {
-		log.Printf("Error during server operations: %v", err)
+		slog.Error("HTTPS server shutdown failed", "error", err)
 	}
-}
-
-func setupLogging() {
-	// 创建日志目录
-	logDir := "logs"
-	err := os.MkdirAll(logDir, 0755)
-	if err != nil {
-		log.Fatal("无法创建日志目录:", err)
-	}
-
-	// 创建日志文件，使用当前日期作为文件名
-	currentTime := time.Now()
-	logFileName := filepath.Join(logDir, fmt.Sprintf("%s.log", currentTime.Format("2006-01-02")))
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatal("无法创建日志文件:", err)
-	}
-
-	// 设置 gin 的日志输出到文件和控制台
-	gin.DefaultWriter = io.MultiWriter(logFile, os.Stdout)
 
-	// 设置标准日志输出到文件和控制台
-	log.SetOutput(io.MultiWriter(logFile, os.Stdout))
-	log.SetPrefix("[Copilot Proxies] ")
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-}
-
-// initDefaultEnv 初始化默认环境变量
-func initDefaultEnv() {
-	if os.Getenv("COPILOT_PROXY_ALL") == "" {
-		os.Setenv("COPILOT_PROXY_ALL", "false")
-	}
-
-	if os.Getenv("COPILOT_CLIENT_TYPE") == "" {
-		os.Setenv("COPILOT_CLIENT_TYPE", "default")
-	}
-
-	if os.Getenv("DISGUISE_COPILOT_TOKEN_EXPIRES_AT") == "" {
-		os.Setenv("DISGUISE_COPILOT_TOKEN_EXPIRES_AT", "1800")
-	}
-
-	if os.Getenv("HTTP_CLIENT_TIMEOUT") == "" {
-		os.Setenv("DISGUISE_COPILOT_TOKEN_EXPIRES_AT", "60")
-	}
-
-	if os.Getenv("COPILOT_ACCOUNT_TYPE") == "" {
-		os.Setenv("COPILOT_ACCOUNT_TYPE", "individual")
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("metrics server shutdown failed", "error", err)
+		}
 	}
 
-	if os.Getenv("LIGHTWEIGHT_MODEL") == "" {
-		os.Setenv("LIGHTWEIGHT_MODEL", "gpt-4o-mini")
+	// 等待所有 goroutine 完成
+	if err := g.Wait(); err != nil && err != http.ErrServerClosed {
+		slog.Error("error during server operations", "error", err)
 	}
-
 }
@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"os"
 	"reflect"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,6 +20,14 @@ type LoadModel interface {
 	GetAudience() (jwt.ClaimStrings, error)
 }
 
+// signingKey 签名秘钥, 通过 SetSigningKey 设置, 配置热加载时会被替换
+var signingKey atomic.Value
+
+// SetSigningKey 设置签名秘钥
+func SetSigningKey(key string) {
+	signingKey.Store([]byte(key))
+}
+
 // JWT jwt对象
 type JWT struct {
 	// 声明签名信息
@@ -28,8 +36,9 @@ type JWT struct {
 
 // NewJWT 初始化jwt对象
 func NewJWT() *JWT {
+	key, _ := signingKey.Load().([]byte)
 	return &JWT{
-		[]byte(os.Getenv("TOKEN_SALT")),
+		key,
 	}
 }
 
@@ -1,5 +1,90 @@
 package logs
 
-func Init() {
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
 
+// Options 日志配置
+type Options struct {
+	Level   string // debug、info、warn 或 error
+	Format  string // json 或 text
+	Dir     string // 日志目录, 为空时只输出到控制台
+	MaxSize int    // 单个日志文件的最大大小, 单位 MB, 0 表示不按大小切割
+	MaxAge  int    // 日志文件保留天数, 0 表示不清理
 }
+
+// level 全局日志级别, 配置热加载时通过 SetLevel 修改
+var level = new(slog.LevelVar)
+
+// Init 初始化全局日志, 标准库 log 的输出也会转为 info 级别的结构化日志
+// 返回的 io.Closer 用于在退出时关闭日志文件
+func Init(opts Options) (io.Closer, error) {
+	if err := SetLevel(opts.Level); err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = os.Stdout
+	var closer io.Closer = nopCloser{}
+	if opts.Dir != "" {
+		w, err := NewRotateWriter(opts.Dir, opts.MaxSize, opts.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		out = io.MultiWriter(w, os.Stdout)
+		closer = w
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "json":
+		h = slog.NewJSONHandler(out, handlerOpts)
+	case "text":
+		h = slog.NewTextHandler(out, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	slog.SetDefault(slog.New(&contextHandler{Handler: h}))
+	return closer, nil
+}
+
+// SetLevel 修改全局日志级别, 为空时使用 info
+func SetLevel(s string) error {
+	if s == "" {
+		s = "info"
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return fmt.Errorf("unknown log level %q", s)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Writer 将写入的每一行作为指定级别的日志输出, 用于接管第三方库的日志
+func Writer(l slog.Level) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				slog.Log(context.Background(), l, line)
+			}
+		}
+		return len(p), nil
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
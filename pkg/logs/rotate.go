@@ -0,0 +1,141 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dateLayout 日志文件名中的日期格式
+const dateLayout = "2006-01-02"
+
+// RotateWriter 按天及文件大小切割的日志文件
+// 当前日志文件为 <日期>.log, 超过大小限制后重命名为 <日期>.<序号>.log, 超过保留天数的日志文件会被删除
+type RotateWriter struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu   sync.Mutex
+	file *os.File
+	date string
+	size int64
+}
+
+// NewRotateWriter 创建日志文件, maxSize 单位为 MB, maxAge 单位为天, 为 0 时不按大小切割或不清理
+func NewRotateWriter(dir string, maxSize int, maxAge int) (*RotateWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory %s: %v", dir, err)
+	}
+	w := &RotateWriter{
+		dir:     dir,
+		maxSize: int64(maxSize) << 20,
+		maxAge:  time.Duration(maxAge) * 24 * time.Hour,
+	}
+	if err := w.open(time.Now().Format(dateLayout)); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write 写入日志, 日期变化或超过大小限制时先切割
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if date := time.Now().Format(dateLayout); date != w.date || w.file == nil {
+		if err := w.open(date); err != nil {
+			return 0, err
+		}
+	} else if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close 关闭日志文件
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open 打开指定日期的日志文件并清理过期日志
+func (w *RotateWriter) open(date string) error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+
+	f, err := os.OpenFile(w.path(date, 0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file, w.date, w.size = f, date, info.Size()
+
+	w.cleanup()
+	return nil
+}
+
+// rotate 将当前日志文件重命名为下一个序号并重新打开
+func (w *RotateWriter) rotate() error {
+	_ = w.file.Close()
+	w.file = nil
+
+	for i := 1; ; i++ {
+		backup := w.path(w.date, i)
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			if err := os.Rename(w.path(w.date, 0), backup); err != nil {
+				return fmt.Errorf("failed to rotate log file: %v", err)
+			}
+			break
+		}
+	}
+	return w.open(w.date)
+}
+
+// cleanup 删除超过保留天数的日志文件
+func (w *RotateWriter) cleanup() {
+	if w.maxAge <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(-w.maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(deadline) {
+			_ = os.Remove(filepath.Join(w.dir, entry.Name()))
+		}
+	}
+}
+
+func (w *RotateWriter) path(date string, index int) string {
+	if index == 0 {
+		return filepath.Join(w.dir, date+".log")
+	}
+	return filepath.Join(w.dir, fmt.Sprintf("%s.%d.log", date, index))
+}
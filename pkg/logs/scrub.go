@@ -0,0 +1,61 @@
+package logs
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// redacted 脱敏后的占位符
+const redacted = "***"
+
+// minSecretLength 短于该长度的配置值不做精确替换, 避免误伤普通文本
+const minSecretLength = 8
+
+// secretPatterns 常见密钥格式, 未在配置中出现的密钥 (如插件请求头中的令牌) 也能被脱敏
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bgh[opsur]_[A-Za-z0-9]{20,}`),                   // GitHub token, 如 ghu_ gho_
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`),                 // GitHub fine-grained token
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`),                       // OpenAI、DeepSeek、Anthropic 等 API key
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{30,}`),                      // Google API key
+	regexp.MustCompile(`\bcpx_[A-Za-z0-9]{16,}`),                         // 用户模式访问令牌
+	regexp.MustCompile(`(?i)\b(bearer|token)\s+[A-Za-z0-9._\-=;:]{16,}`), // Authorization 请求头
+}
+
+// secrets 配置中的密钥, 通过 SetSecrets 设置
+var secrets atomic.Pointer[strings.Replacer]
+
+// SetSecrets 设置需要脱敏的密钥, 如配置中的 API key 和 GHU token, 配置热加载后需要重新设置
+func SetSecrets(values []string) {
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if len(v) >= minSecretLength {
+			list = append(list, v)
+		}
+	}
+	// 较长的密钥优先替换, 避免密钥互为前缀时只替换一部分
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+
+	pairs := make([]string, 0, len(list)*2)
+	for _, v := range list {
+		pairs = append(pairs, v, redacted)
+	}
+	secrets.Store(strings.NewReplacer(pairs...))
+}
+
+// Scrub 脱敏文本中的密钥
+func Scrub(s string) string {
+	if r := secrets.Load(); r != nil {
+		s = r.Replace(s)
+	}
+	for i, re := range secretPatterns {
+		if i == len(secretPatterns)-1 {
+			// Authorization 请求头保留类型, 只替换令牌部分
+			s = re.ReplaceAllString(s, "${1} "+redacted)
+			continue
+		}
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"ripper/internal/app/capture"
+	"ripper/internal/app/github_auth"
+	"ripper/internal/config"
+
+	"github.com/gofrs/uuid"
+)
+
+// skipReplayHeaders 重放时不发送的请求头, 由 http.Client 重新生成
+var skipReplayHeaders = map[string]bool{
+	"Content-Length":  true,
+	"Host":            true,
+	"Accept-Encoding": true,
+	"Connection":      true,
+}
+
+// replay 将捕获的请求重新发送到当前配置下运行的服务, 响应原样输出到标准输出, 用于复现问题
+// 用法: ripper replay -file data/captures/user_1/ChatCompletions-2024-01-01.jsonl [-id 请求ID] [-addr http://127.0.0.1:8080] [-token 令牌]
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := fs.String("file", "", "capture file (.jsonl)")
+	id := fs.String("id", "", "request id to replay, defaults to the last record in the file")
+	addr := fs.String("addr", "", "server address, defaults to http://127.0.0.1:<PORT>")
+	token := fs.String("token", "", "token sent as Authorization, required in user mode and for HandleChunks")
+	_ = fs.Parse(args)
+	if *file == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, _ := loadConfig()
+	record, err := capture.Find(*file, *id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if record.Truncated {
+		log.Printf("Warning: capture %s is truncated, the replayed request may differ", record.ID)
+	}
+
+	if *addr == "" {
+		*addr = fmt.Sprintf("http://127.0.0.1:%d", cfg.Server.Port)
+	}
+	authorization, err := replayAuthorization(cfg, record, *token)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req, err := http.NewRequest(record.Method, strings.TrimSuffix(*addr, "/")+record.Path, bytes.NewReader([]byte(record.Body)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, values := range record.Header {
+		if skipReplayHeaders[http.CanonicalHeaderKey(name)] || capture.IsRedacted(name) {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	fmt.Fprintf(os.Stderr, "replaying %s %s %s (captured status %d)\n", record.ID, record.Method, record.Path, record.Status)
+	fmt.Fprintf(os.Stderr, "status %d, request id %s\n", resp.StatusCode, resp.Header.Get("x-github-request-id"))
+	if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "\nfinished in %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+// replayAuthorization 生成重放请求的 Authorization 请求头
+// 未指定令牌时, 非用户模式下的补全和嵌入接口使用 TOKEN_SALT 签发临时 Copilot token, 其他情况必须通过 -token 指定
+func replayAuthorization(cfg *config.Config, record *capture.Record, token string) (string, error) {
+	if token != "" {
+		return "Bearer " + token, nil
+	}
+	if record.Route == "HandleChunks" {
+		return "", fmt.Errorf("-token is required to replay %s", record.Route)
+	}
+	// 直连 GitHub 时补全接口不校验 token
+	if cfg.IsGithub() && !cfg.ProxyAll() {
+		return "", nil
+	}
+	if cfg.Auth.UserAuth {
+		return "", fmt.Errorf("-token is required to replay %s in user mode", record.Route)
+	}
+
+	trackingId, _ := uuid.NewV4()
+	claims := map[string]interface{}{
+		"tid":  trackingId,
+		"exp":  time.Now().Unix() + 300,
+		"sku":  "copilot_for_business_seat",
+		"st":   "dotcom",
+		"chat": 1,
+		"u":    "github",
+	}
+	return "Bearer " + github_auth.JsonMap2SignToken(claims), nil
+}